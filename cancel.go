@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// CancelledError stops a script whose context was cancelled or timed out.
+// It wraps the context's error, so errors.Is(err, context.Canceled) and
+// errors.Is(err, context.DeadlineExceeded) work on it.
+type CancelledError struct {
+	token Token
+	err   error
+}
+
+func (e *CancelledError) Error() string {
+	message := fmt.Sprintf("Error: script stopped: %v.", e.err)
+	if e.token.Line == 0 {
+		return message
+	}
+	return fmt.Sprintf("[line %d] %s", e.token.Line, message)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.err
+}
+
+// interpretContext runs statements until they finish or ctx is done. The
+// context is checked before every loop iteration and every call; blocks
+// unwind as they do for any runtime error, so the interpreter is back at
+// the environment it started in when this returns.
+func (i *Interpreter) interpretContext(ctx context.Context, statements []*Stmt) (interface{}, error) {
+	previous := i.ctx
+	i.ctx = ctx
+	defer func() { i.ctx = previous }()
+	if err := i.checkCancelled(Token{}); err != nil {
+		return nil, err
+	}
+	return i.interpret(statements)
+}
+
+func (i *Interpreter) checkCancelled(token Token) error {
+	if i.ctx == nil {
+		return nil
+	}
+	select {
+	case <-i.ctx.Done():
+		return &CancelledError{token: token, err: i.ctx.Err()}
+	default:
+		return nil
+	}
+}
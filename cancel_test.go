@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runContext(t *testing.T, ctx context.Context, source string) (*Interpreter, string, error) {
+	t.Helper()
+	statements := parseSource(t, source)
+	var output bytes.Buffer
+	interpreter := NewInterpreter()
+	interpreter.setStreams(strings.NewReader(""), &output, &output)
+	resolver := NewResolver(interpreter)
+	if err := resolver.resolveStatements(statements); err != nil {
+		t.Fatal(err)
+	}
+	_, err := interpreter.interpretContext(ctx, statements)
+	interpreter.waitForTasks()
+	interpreter.flush()
+	return interpreter, output.String(), err
+}
+
+func TestCancel(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		source  string
+		output  string
+		target  error
+		message string
+	}{
+		{
+			name: "timeout in a loop",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			source:  "print 1;\nwhile (true) {}",
+			output:  "1\n",
+			target:  context.DeadlineExceeded,
+			message: "[line 2] Error: script stopped: context deadline exceeded.",
+		},
+		{
+			name: "timeout in a call",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			source:  "fun spin() {\n  while (true) {}\n}\n{ var a = 1; spin(); }",
+			target:  context.DeadlineExceeded,
+			message: "[line 2] Error: script stopped: context deadline exceeded.",
+		},
+		{
+			name: "cancelled while running",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			source:  "var i = 0;\nwhile (true) i = i + 1;",
+			target:  context.Canceled,
+			message: "[line 2] Error: script stopped: context canceled.",
+		},
+		{
+			name:    "cancelled before starting",
+			ctx:     func() (context.Context, context.CancelFunc) { return cancelled, func() {} },
+			source:  "print 1;",
+			target:  context.Canceled,
+			message: "Error: script stopped: context canceled.",
+		},
+		{
+			name: "finishes in time",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Minute)
+			},
+			source: "var i = 0;\nwhile (i < 100) i = i + 1;\nprint i;",
+			output: "100\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := test.ctx()
+			defer cancel()
+			interpreter, output, err := runContext(t, ctx, test.source)
+			if output != test.output {
+				t.Errorf("expected output %q, got %q", test.output, output)
+			}
+			if test.target == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var cancelledError *CancelledError
+			if !errors.As(err, &cancelledError) || !errors.Is(err, test.target) {
+				t.Fatalf("expected a CancelledError wrapping %v, got %v", test.target, err)
+			}
+			if err.Error() != test.message {
+				t.Errorf("expected %q, got %q", test.message, err)
+			}
+			if interpreter.environment != interpreter.globals || interpreter.ctx != nil {
+				t.Errorf("the interpreter wasn't restored after stopping")
+			}
+		})
+	}
+}
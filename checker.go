@@ -0,0 +1,722 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Checker compares the values a script uses against its type annotations.
+// It runs after the Resolver and follows its references to find the
+// declaration, and so the annotation, behind each name. Types are only
+// inferred within an expression: an unannotated variable, parameter or
+// return value is Any, which is compatible with every type.
+type Checker struct {
+	resolver *Resolver
+	// annotated variables and parameters, by the token declaring them
+	types map[Token]Type
+	// functions by the token naming them
+	functions map[Token]StmtFunction
+	classes   map[string]*checkedClass
+	// the class whose methods are being checked, if any
+	currentClass *checkedClass
+	// the declared return type of the function being checked, if any
+	returnType *Type
+	errors     []*TypeError
+}
+
+type Type struct {
+	Name string
+	// optional types admit nil
+	Optional bool
+}
+
+var (
+	ANY_TYPE       = Type{Name: "Any"}
+	NUMBER_TYPE    = Type{Name: "Number"}
+	STRING_TYPE    = Type{Name: "String"}
+	BOOL_TYPE      = Type{Name: "Bool"}
+	NIL_TYPE       = Type{Name: "Nil"}
+	FUNCTION_TYPE  = Type{Name: "Function"}
+	LIST_TYPE      = Type{Name: "List"}
+	GENERATOR_TYPE = Type{Name: "Generator"}
+)
+
+var builtinTypes = []Type{ANY_TYPE, NUMBER_TYPE, STRING_TYPE, BOOL_TYPE, NIL_TYPE, FUNCTION_TYPE, LIST_TYPE, GENERATOR_TYPE}
+
+func (t Type) String() string {
+	if t.Optional {
+		return t.Name + "?"
+	}
+	return t.Name
+}
+
+type checkedClass struct {
+	stmt       StmtClass
+	superclass string
+	fields     map[string]*TypeAnnotation
+	methods    map[string]StmtFunction
+}
+
+type TypeError struct {
+	token   Token
+	message string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("[line %d:%d] Type error at '%s': %s", e.token.Line, e.token.Column, e.token.Lexeme, e.message)
+}
+
+func NewChecker(resolver *Resolver) Checker {
+	return Checker{
+		resolver:  resolver,
+		types:     make(map[Token]Type),
+		functions: make(map[Token]StmtFunction),
+		classes:   make(map[string]*checkedClass),
+	}
+}
+
+// check reports every mismatch in statements, which must have been
+// resolved by the checker's resolver.
+func (c *Checker) check(statements []*Stmt) []*TypeError {
+	c.collectDeclarations(statements)
+	c.checkStatements(statements)
+	return c.errors
+}
+
+// collectDeclarations finds every function and class up front, so that
+// calls and annotations can refer to those declared further down.
+func (c *Checker) collectDeclarations(statements []*Stmt) {
+	for _, stmt := range statements {
+		c.collectDeclaration(*stmt)
+	}
+}
+
+func (c *Checker) collectDeclaration(stmt Stmt) {
+	switch s := stmt.(type) {
+	case StmtBlock:
+		c.collectDeclarations(s.Statements)
+	case StmtIf:
+		c.collectDeclaration(s.ThenBranch)
+		if s.ElseBranch != nil {
+			c.collectDeclaration(s.ElseBranch)
+		}
+	case StmtWhile:
+		c.collectDeclaration(s.Body)
+	case StmtForIn:
+		c.collectDeclaration(s.Body)
+	case StmtSelect:
+		for _, selectCase := range s.Cases {
+			c.collectDeclaration(selectCase.Body)
+		}
+	case StmtMatch:
+		for _, matchCase := range s.Cases {
+			c.collectDeclaration(matchCase.Body)
+		}
+	case StmtFunction:
+		c.functions[s.Name] = s
+		c.collectDeclarations(s.Body)
+	case StmtClass:
+		class := &checkedClass{
+			stmt:    s,
+			fields:  make(map[string]*TypeAnnotation),
+			methods: make(map[string]StmtFunction),
+		}
+		if s.Superclass != nil {
+			class.superclass = s.Superclass.Name.Lexeme
+		}
+		for _, field := range s.Fields {
+			class.fields[field.Name.Lexeme] = field.Type
+		}
+		for _, method := range s.Methods {
+			function := method.(StmtFunction)
+			class.methods[function.Name.Lexeme] = function
+			c.collectDeclarations(function.Body)
+		}
+		c.classes[s.Name.Lexeme] = class
+	}
+}
+
+func (c *Checker) error(token Token, message string) {
+	c.errors = append(c.errors, &TypeError{token: token, message: message})
+}
+
+// annotated turns an annotation into a type. A missing annotation, or one
+// naming an unknown type, is Any.
+func (c *Checker) annotated(annotation *TypeAnnotation) Type {
+	if annotation == nil || !c.isType(annotation.Name.Lexeme) {
+		return ANY_TYPE
+	}
+	return Type{Name: annotation.Name.Lexeme, Optional: annotation.Optional}
+}
+
+// declared is annotated for the annotation of a declaration, where unknown
+// type names are reported.
+func (c *Checker) declared(annotation *TypeAnnotation) Type {
+	if annotation != nil && !c.isType(annotation.Name.Lexeme) {
+		c.error(annotation.Name, fmt.Sprintf("Unknown type '%s'.", annotation.Name.Lexeme))
+	}
+	return c.annotated(annotation)
+}
+
+func (c *Checker) isType(name string) bool {
+	_, ok := c.classes[name]
+	return ok || isBuiltinType(name)
+}
+
+func isBuiltinType(name string) bool {
+	for _, builtin := range builtinTypes {
+		if builtin.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// assignable reports whether a value of type from may be stored where to
+// is expected.
+func (c *Checker) assignable(from Type, to Type) bool {
+	if from.Name == ANY_TYPE.Name || to.Name == ANY_TYPE.Name {
+		return true
+	}
+	if from.Name == NIL_TYPE.Name {
+		return to.Optional || to.Name == NIL_TYPE.Name
+	}
+	if from.Optional && !to.Optional {
+		return false
+	}
+	if from.Name == to.Name {
+		return true
+	}
+	for _, class := range c.lineage(from.Name) {
+		if class.stmt.Name.Lexeme == to.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// lineage lists a class and its superclasses. It stops at a cycle, which
+// only fails once the script runs.
+func (c *Checker) lineage(name string) []*checkedClass {
+	var classes []*checkedClass
+	seen := make(map[string]bool)
+	for class := c.classes[name]; class != nil && !seen[class.stmt.Name.Lexeme]; class = c.classes[class.superclass] {
+		seen[class.stmt.Name.Lexeme] = true
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+func (c *Checker) expect(token Token, what string, from Type, to Type) {
+	if !c.assignable(from, to) {
+		c.error(token, fmt.Sprintf("%s must be %v but is %v.", what, to, from))
+	}
+}
+
+func (c *Checker) checkStatements(statements []*Stmt) {
+	for _, stmt := range statements {
+		c.checkStmt(*stmt)
+	}
+}
+
+func (c *Checker) checkStmt(stmt Stmt) {
+	stmt.accept(c)
+}
+
+func (c *Checker) typeOf(expr Expr) Type {
+	value, _ := expr.accept(c)
+	return value.(Type)
+}
+
+// declaration finds what the resolver linked name to.
+func (c *Checker) declaration(name Token) *Declaration {
+	return c.resolver.references[name]
+}
+
+// findField looks up the annotation of a field through the superclasses.
+func (c *Checker) findField(className string, name string) (*TypeAnnotation, bool) {
+	for _, class := range c.lineage(className) {
+		if annotation, ok := class.fields[name]; ok {
+			return annotation, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Checker) findMethod(className string, name string) (StmtFunction, bool) {
+	for _, class := range c.lineage(className) {
+		if method, ok := class.methods[name]; ok {
+			return method, true
+		}
+	}
+	return StmtFunction{}, false
+}
+
+// checkArguments compares the arguments of a call with the parameters of
+// the function called. Arity and unknown names are the linter's business.
+func (c *Checker) checkArguments(expr ExprCall, function StmtFunction, types []Type) {
+	positional := len(types) - len(expr.Names)
+	for index, argument := range types {
+		param := index
+		if index >= positional {
+			param = -1
+			for candidate, name := range function.Params {
+				if name.Lexeme == expr.Names[index-positional].Lexeme {
+					param = candidate
+				}
+			}
+		}
+		if param < 0 || param >= len(function.ParamTypes) || function.ParamTypes[param] == nil {
+			// the rest parameter has no annotation either
+			continue
+		}
+		expected := c.annotated(function.ParamTypes[param])
+		c.expect(expr.Paren, fmt.Sprintf("Argument %d of '%s'", index+1, function.Name.Lexeme), argument, expected)
+	}
+}
+
+func (c *Checker) visitStmtVarDeclaration(stmt StmtVarDeclaration) error {
+	declared := c.declared(stmt.Type)
+	if stmt.Type != nil {
+		c.types[stmt.Name] = declared
+	}
+	if stmt.Initializer != nil {
+		c.expect(stmt.Name, fmt.Sprintf("'%s'", stmt.Name.Lexeme), c.typeOf(*stmt.Initializer), declared)
+	}
+	return nil
+}
+
+func (c *Checker) visitStmtFunction(stmt StmtFunction) error {
+	c.checkFunction(stmt)
+	return nil
+}
+
+func (c *Checker) checkFunction(stmt StmtFunction) {
+	for index, param := range stmt.Params {
+		if stmt.Rest && index == len(stmt.Params)-1 {
+			c.types[param] = LIST_TYPE
+			continue
+		}
+		annotation := stmt.ParamTypes[index]
+		if annotation != nil {
+			c.types[param] = c.declared(annotation)
+		}
+		if value := stmt.Defaults[index]; value != nil {
+			valueType := c.typeOf(*value)
+			if annotation != nil {
+				c.expect(param, fmt.Sprintf("Default of '%s'", param.Lexeme), valueType, c.types[param])
+			}
+		}
+	}
+	enclosing := c.returnType
+	c.returnType = nil
+	if stmt.ReturnType != nil {
+		returnType := c.declared(stmt.ReturnType)
+		if stmt.Generator {
+			// the type is that of the call, as the body only returns nil
+			c.expect(stmt.ReturnType.Name, fmt.Sprintf("Return type of generator '%s'", stmt.Name.Lexeme), GENERATOR_TYPE, returnType)
+		} else {
+			c.returnType = &returnType
+		}
+	}
+	c.checkStatements(stmt.Body)
+	c.returnType = enclosing
+}
+
+func (c *Checker) visitStmtClass(stmt StmtClass) error {
+	enclosing := c.currentClass
+	c.currentClass = c.classes[stmt.Name.Lexeme]
+	for _, field := range stmt.Fields {
+		c.declared(field.Type)
+	}
+	for _, method := range stmt.Methods {
+		c.checkFunction(method.(StmtFunction))
+	}
+	c.currentClass = enclosing
+	return nil
+}
+
+func (c *Checker) visitStmtReturn(stmt StmtReturn) error {
+	returned := NIL_TYPE
+	if stmt.Value != nil {
+		returned = c.typeOf(stmt.Value)
+	}
+	if c.returnType != nil {
+		c.expect(stmt.Keyword, "Return value", returned, *c.returnType)
+	}
+	return nil
+}
+
+func (c *Checker) visitStmtExpression(stmt StmtExpression) error {
+	c.typeOf(*stmt.Expression)
+	return nil
+}
+
+func (c *Checker) visitStmtPrint(stmt StmtPrint) error {
+	c.typeOf(stmt.Expression)
+	return nil
+}
+
+func (c *Checker) visitStmtBlock(stmt StmtBlock) error {
+	c.checkStatements(stmt.Statements)
+	return nil
+}
+
+func (c *Checker) visitStmtIf(stmt StmtIf) error {
+	c.typeOf(stmt.Condition)
+	c.checkStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		c.checkStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (c *Checker) visitStmtWhile(stmt StmtWhile) error {
+	c.typeOf(stmt.Condition)
+	c.checkStmt(stmt.Body)
+	return nil
+}
+
+func (c *Checker) visitStmtForIn(stmt StmtForIn) error {
+	iterable := c.typeOf(stmt.Iterable)
+	if !c.assignable(iterable, LIST_TYPE) && !c.assignable(iterable, GENERATOR_TYPE) {
+		c.error(stmt.Keyword, fmt.Sprintf("Can't iterate over %v.", iterable))
+	}
+	c.checkStmt(stmt.Body)
+	return nil
+}
+
+func (c *Checker) visitStmtSelect(stmt StmtSelect) error {
+	for _, selectCase := range stmt.Cases {
+		if selectCase.Channel != nil {
+			c.typeOf(selectCase.Channel)
+		}
+		if selectCase.Value != nil {
+			c.typeOf(selectCase.Value)
+		}
+		c.checkStmt(selectCase.Body)
+	}
+	return nil
+}
+
+func (c *Checker) visitStmtYield(stmt StmtYield) error {
+	if stmt.Value != nil {
+		c.typeOf(stmt.Value)
+	}
+	return nil
+}
+
+func (c *Checker) visitStmtMatch(stmt StmtMatch) error {
+	c.typeOf(stmt.Subject)
+	for _, matchCase := range stmt.Cases {
+		c.checkStmt(matchCase.Body)
+	}
+	return nil
+}
+
+// visitStmtDestructure types names taken from the fields of an instance
+// of a known class by the fields' annotations.
+func (c *Checker) visitStmtDestructure(stmt StmtDestructure) error {
+	value := c.typeOf(stmt.Initializer)
+	if stmt.Target.isList() {
+		c.expect(stmt.Target.Bracket, "Destructured value", value, LIST_TYPE)
+		return nil
+	}
+	if value.Optional {
+		c.error(stmt.Target.Bracket, fmt.Sprintf("Destructured value of type %v may be nil.", value))
+	}
+	for _, target := range stmt.Target.Targets {
+		if annotation, ok := c.findField(value.Name, target.Name.Lexeme); ok {
+			c.types[target.Name] = c.annotated(annotation)
+		}
+	}
+	return nil
+}
+
+func (c *Checker) visitLiteralExpr(expr ExprLiteral) (interface{}, error) {
+	switch expr.Value.(type) {
+	case int64, float64:
+		return NUMBER_TYPE, nil
+	case string:
+		return STRING_TYPE, nil
+	case bool:
+		return BOOL_TYPE, nil
+	case nil:
+		return NIL_TYPE, nil
+	}
+	return ANY_TYPE, nil
+}
+
+func (c *Checker) visitGroupingExpr(expr ExprGrouping) (interface{}, error) {
+	return c.typeOf(*expr.Expression), nil
+}
+
+func (c *Checker) visitInterpolationExpr(expr ExprInterpolation) (interface{}, error) {
+	for _, part := range expr.Parts {
+		c.typeOf(*part)
+	}
+	return STRING_TYPE, nil
+}
+
+func (c *Checker) visitUnaryExpr(expr ExprUnary) (interface{}, error) {
+	c.typeOf(*expr.Right)
+	if expr.Operator.TokenType == BANG {
+		return BOOL_TYPE, nil
+	}
+	return NUMBER_TYPE, nil
+}
+
+func (c *Checker) visitBinaryExpr(expr ExprBinary) (interface{}, error) {
+	return binaryType(expr.Operator.TokenType, c.typeOf(expr.Left), c.typeOf(expr.Right)), nil
+}
+
+// binaryType infers the result of a binary operator from its operands.
+func binaryType(operator TokenType, left Type, right Type) Type {
+	switch operator {
+	case EQUAL_EQUAL, BANG_EQUAL, GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		return BOOL_TYPE
+	case PLUS:
+		if left == NUMBER_TYPE && right == NUMBER_TYPE {
+			return NUMBER_TYPE
+		}
+		if left == STRING_TYPE || right == STRING_TYPE {
+			return STRING_TYPE
+		}
+		return ANY_TYPE
+	}
+	return NUMBER_TYPE
+}
+
+func (c *Checker) visitLogicalExpr(expr ExprLogical) (interface{}, error) {
+	left, right := c.typeOf(*expr.Left), c.typeOf(*expr.Right)
+	if expr.Operator.TokenType == QUESTION_QUESTION {
+		left.Optional = false
+		if c.assignable(left, right) {
+			return right, nil
+		}
+		return ANY_TYPE, nil
+	}
+	if left == right {
+		return left, nil
+	}
+	return ANY_TYPE, nil
+}
+
+func (c *Checker) visitConditionalExpr(expr ExprConditional) (interface{}, error) {
+	c.typeOf(*expr.Condition)
+	then, otherwise := c.typeOf(*expr.Then), c.typeOf(*expr.Else)
+	switch {
+	case then == otherwise:
+		return then, nil
+	// a nil branch keeps a type optional only when it was annotated so;
+	// unannotated code isn't checked for nil
+	case then == NIL_TYPE && otherwise.Optional:
+		return otherwise, nil
+	case otherwise == NIL_TYPE && then.Optional:
+		return then, nil
+	}
+	return ANY_TYPE, nil
+}
+
+func (c *Checker) visitVariableExpr(expr ExprVariable) (interface{}, error) {
+	declaration := c.declaration(expr.Name)
+	if declaration == nil {
+		return ANY_TYPE, nil
+	}
+	switch declaration.Kind {
+	case FUNCTION_DECLARATION, CLASS_DECLARATION:
+		return FUNCTION_TYPE, nil
+	}
+	if declared, ok := c.types[declaration.Name]; ok {
+		return declared, nil
+	}
+	return ANY_TYPE, nil
+}
+
+// visitSpawnExpr checks the call spawned, which evaluates to a task
+// rather than to what the callee returns.
+func (c *Checker) visitSpawnExpr(expr ExprSpawn) (interface{}, error) {
+	c.typeOf(expr.Call)
+	return ANY_TYPE, nil
+}
+
+func (c *Checker) visitListExpr(expr ExprList) (interface{}, error) {
+	for _, element := range expr.Elements {
+		c.typeOf(*element)
+	}
+	return LIST_TYPE, nil
+}
+
+func (c *Checker) visitIndexExpr(expr ExprIndex) (interface{}, error) {
+	element, skipped := c.indexType(expr)
+	return skippable(element, skipped), nil
+}
+
+func (c *Checker) indexType(expr ExprIndex) (Type, bool) {
+	object, skipped := c.chainType(*expr.Object)
+	c.checkIndex(object, expr.Bracket, *expr.Index)
+	return ANY_TYPE, skipped
+}
+
+func (c *Checker) visitIndexSetExpr(expr ExprIndexSet) (interface{}, error) {
+	c.checkIndex(c.typeOf(*expr.Object), expr.Bracket, *expr.Index)
+	return c.typeOf(*expr.Value), nil
+}
+
+// checkIndex checks list[index]; the elements of a list have no static
+// types.
+func (c *Checker) checkIndex(object Type, bracket Token, index Expr) {
+	c.expect(bracket, "Indexed value", object, LIST_TYPE)
+	c.expect(bracket, "List index", c.typeOf(index), NUMBER_TYPE)
+}
+
+// chainType is the type of the object of a property access or subscript,
+// or of the callee of a call, as if no '?.' before it skipped the chain;
+// skipped tells whether one may.
+func (c *Checker) chainType(expr Expr) (Type, bool) {
+	switch link := expr.(type) {
+	case ExprGet:
+		return c.getType(link)
+	case ExprCall:
+		return c.callType(link)
+	case ExprIndex:
+		return c.indexType(link)
+	}
+	return c.typeOf(expr), false
+}
+
+// skippable makes the type of a chain optional when a '?.' may skip it.
+// Any admits nil already.
+func skippable(t Type, skipped bool) Type {
+	if skipped && t.Name != ANY_TYPE.Name {
+		t.Optional = true
+	}
+	return t
+}
+
+// visitDestructureExpr checks a destructuring assignment like a list
+// declaration; the elements of a list have no static types.
+func (c *Checker) visitDestructureExpr(expr ExprDestructure) (interface{}, error) {
+	value := c.typeOf(*expr.Value)
+	c.expect(expr.Target.Bracket, "Destructured value", value, LIST_TYPE)
+	return value, nil
+}
+
+func (c *Checker) visitAssignExpr(expr ExprAssign) (interface{}, error) {
+	value := c.typeOf(*expr.Value)
+	if declaration := c.declaration(expr.Name); declaration != nil {
+		if declared, ok := c.types[declaration.Name]; ok {
+			c.expect(expr.Name, fmt.Sprintf("'%s'", expr.Name.Lexeme), value, declared)
+		}
+	}
+	return value, nil
+}
+
+func (c *Checker) visitUpdateExpr(expr ExprUpdate) (interface{}, error) {
+	target := c.typeOf(expr.Target)
+	value := binaryType(expr.Operator.TokenType, target, c.typeOf(*expr.Value))
+	switch t := expr.Target.(type) {
+	case ExprVariable:
+		c.expect(t.Name, fmt.Sprintf("'%s'", t.Name.Lexeme), value, target)
+	case ExprGet:
+		c.expect(t.Name, fmt.Sprintf("Field '%s'", t.Name.Lexeme), value, target)
+	}
+	return value, nil
+}
+
+func (c *Checker) visitCallExpr(expr ExprCall) (interface{}, error) {
+	returned, skipped := c.callType(expr)
+	return skippable(returned, skipped), nil
+}
+
+func (c *Checker) callType(expr ExprCall) (Type, bool) {
+	arguments := make([]Type, len(expr.Arguments))
+	for index, argument := range expr.Arguments {
+		arguments[index] = c.typeOf(*argument)
+	}
+	switch callee := (*expr.Callee).(type) {
+	case ExprVariable:
+		declaration := c.declaration(callee.Name)
+		if declaration == nil {
+			break
+		}
+		if function, ok := c.functions[declaration.Name]; ok {
+			c.checkArguments(expr, function, arguments)
+			if function.Generator {
+				return GENERATOR_TYPE, false
+			}
+			return c.annotated(function.ReturnType), false
+		}
+		if declaration.Kind == CLASS_DECLARATION {
+			if _, ok := c.classes[callee.Name.Lexeme]; ok {
+				if initializer, ok := c.findMethod(callee.Name.Lexeme, "init"); ok {
+					c.checkArguments(expr, initializer, arguments)
+				}
+				return Type{Name: callee.Name.Lexeme}, false
+			}
+		}
+	case ExprGet:
+		object, skipped := c.getObject(callee)
+		if method, ok := c.findMethod(object.Name, callee.Name.Lexeme); ok {
+			c.checkArguments(expr, method, arguments)
+			if method.Generator {
+				return GENERATOR_TYPE, skipped
+			}
+			return c.annotated(method.ReturnType), skipped
+		}
+		return ANY_TYPE, skipped
+	default:
+		_, skipped := c.chainType(*expr.Callee)
+		return ANY_TYPE, skipped
+	}
+	return ANY_TYPE, false
+}
+
+// getObject checks the object of a property access, which must not be nil
+// unless the access is optional. skipped tells whether a '?.' on nil may
+// skip the access.
+func (c *Checker) getObject(expr ExprGet) (object Type, skipped bool) {
+	object, skipped = c.chainType(*expr.Object)
+	if object.Optional && !expr.Optional {
+		c.error(expr.Name, fmt.Sprintf("Object of type %v may be nil; use '?.'.", object))
+	}
+	return object, skipped || (expr.Optional && object.Optional)
+}
+
+func (c *Checker) visitGetExpr(expr ExprGet) (interface{}, error) {
+	property, skipped := c.getType(expr)
+	return skippable(property, skipped), nil
+}
+
+func (c *Checker) getType(expr ExprGet) (Type, bool) {
+	object, skipped := c.getObject(expr)
+	if annotation, ok := c.findField(object.Name, expr.Name.Lexeme); ok {
+		return c.annotated(annotation), skipped
+	}
+	if _, ok := c.findMethod(object.Name, expr.Name.Lexeme); ok {
+		return FUNCTION_TYPE, skipped
+	}
+	return ANY_TYPE, skipped
+}
+
+func (c *Checker) visitSetExpr(expr ExprSet) (interface{}, error) {
+	object := c.typeOf(*expr.Object)
+	value := c.typeOf(*expr.Value)
+	if object.Optional {
+		c.error(expr.Name, fmt.Sprintf("Object of type %v may be nil.", object))
+	}
+	if annotation, ok := c.findField(object.Name, expr.Name.Lexeme); ok {
+		c.expect(expr.Name, fmt.Sprintf("Field '%s'", expr.Name.Lexeme), value, c.annotated(annotation))
+	}
+	return value, nil
+}
+
+func (c *Checker) visitThisExpr(expr ExprThis) (interface{}, error) {
+	if c.currentClass == nil {
+		return ANY_TYPE, nil
+	}
+	return Type{Name: c.currentClass.stmt.Name.Lexeme}, nil
+}
+
+func (c *Checker) visitSuperExpr(expr ExprSuper) (interface{}, error) {
+	return ANY_TYPE, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkSource resolves and type checks source and returns the type errors.
+func checkSource(t *testing.T, source string) []string {
+	t.Helper()
+	statements := parseSource(t, source)
+	resolver := NewResolver(NewInterpreter())
+	if err := resolver.resolveStatements(statements); err != nil {
+		t.Fatalf("resolving: %v", err)
+	}
+	checker := NewChecker(&resolver)
+	var messages []string
+	for _, err := range checker.check(statements) {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+func TestChecker(t *testing.T) {
+	const point = "class Point { x: Number; label: String?; init(x: Number) { this.x = x; } norm(): Number { return this.x; } }\n"
+	tests := []struct {
+		name   string
+		source string
+		errors []string
+	}{
+		{"annotated", `var a: Number = 1; var s: String = "s" + 1; var b: Bool = 1 < 2; var f: Function = clock;`, nil},
+		{"unannotated is Any", `var a = "s"; var n: Number = a;`, nil},
+		{"optional admits nil", `var a: Number? = nil; var b: Number? = 1;`, nil},
+		{"functions", "fun f(a: Number, b: String = \"x\"): String { return b; }\nvar s: String = f(1);", nil},
+		{"classes", point + "var p: Point = Point(1); var n: Number = p.norm(); var x: Number = p.x; p.label = nil;", nil},
+		{"subclass", point + "class Point3 < Point {}\nvar p: Point = Point3(1);", nil},
+		{"optional chain", point + "var p: Point? = nil; var x: Number? = p?.x;", nil},
+		{"coalescing removes nil", point + "var p: Point? = nil; var q: Point = p ?? Point(1);", nil},
+		{"inferred nil is Any", "class P { init(x) { this.x = x; } }\nvar c = true; print (c ? nil : P(1)).x;", nil},
+		{"conditional nil for a class", point + "var c = true; var p: Point = c ? nil : Point(1);", nil},
+		{"declared later", "fun f(): Number { return g(); }\nfun g(): Number { return 1; }", nil},
+
+		{"variable", `var a: Number = "s";`, []string{"[line 1:5] Type error at 'a': 'a' must be Number but is String."}},
+		{"assignment", "var a: String = \"s\";\na = 1;", []string{"[line 2:1] Type error at 'a': 'a' must be String but is Number."}},
+		{"nil for a non-optional", "var a: Number = nil;", []string{"[line 1:5] Type error at 'a': 'a' must be Number but is Nil."}},
+		{"unknown type", "var a: Nombre = 1;", []string{"[line 1:8] Type error at 'Nombre': Unknown type 'Nombre'."}},
+		{"argument", "fun f(a: Number) {}\nf(\"s\");", []string{"[line 2:6] Type error at ')': Argument 1 of 'f' must be Number but is String."}},
+		{"named argument", "fun f(a: Number, b: String = \"\") {}\nf(1, b: 2);", []string{"[line 2:10] Type error at ')': Argument 2 of 'f' must be String but is Number."}},
+		{"default", "fun f(a: Number = \"s\") {}", []string{"[line 1:7] Type error at 'a': Default of 'a' must be Number but is String."}},
+		{"return", "fun f(): String { return 1; }", []string{"[line 1:19] Type error at 'return': Return value must be String but is Number."}},
+		{"missing return value", "fun f(): String { return; }", []string{"[line 1:19] Type error at 'return': Return value must be String but is Nil."}},
+		{"initializer argument", point + "Point(\"s\");", []string{"[line 2:10] Type error at ')': Argument 1 of 'init' must be Number but is String."}},
+		{"field", point + "var p: Point = Point(1);\np.x = \"s\";", []string{"[line 3:3] Type error at 'x': Field 'x' must be Number but is String."}},
+		{"field type", point + "var p: Point = Point(1);\nvar s: String = p.x;", []string{"[line 3:5] Type error at 's': 's' must be String but is Number."}},
+		{"unrelated class", point + "class Other {}\nvar p: Point = Other();", []string{"[line 3:5] Type error at 'p': 'p' must be Point but is Other."}},
+		{"may be nil", point + "var p: Point? = nil;\nprint p.x;", []string{"[line 3:9] Type error at 'x': Object of type Point? may be nil; use '?.'."}},
+		{"conditional keeps an annotated optional", point + "var p: Point? = nil; var c = true;\nprint (c ? nil : p).x;", []string{"[line 3:21] Type error at 'x': Object of type Point? may be nil; use '?.'."}},
+		{"set on optional", point + "var p: Point? = nil;\np.x = 1;", []string{"[line 3:3] Type error at 'x': Object of type Point? may be nil."}},
+		{"chain stays optional", point + "var p: Point? = nil;\nvar x: Number = p?.x;", []string{"[line 3:5] Type error at 'x': 'x' must be Number but is Number?."}},
+		{"compound assignment", "var s: Number = 1;\ns += \"a\";", []string{"[line 2:1] Type error at 's': 's' must be Number but is String."}},
+		{"iteration", "for (var x in 1) print x;", []string{"[line 1:1] Type error at 'for': Can't iterate over Number."}},
+		{"generator return type", "fun g(): Number { yield 1; }", []string{"[line 1:10] Type error at 'Number': Return type of generator 'g' must be Number but is Generator."}},
+		{"several", "var a: Number = \"s\";\nvar b: String = 1;", []string{
+			"[line 1:5] Type error at 'a': 'a' must be Number but is String.",
+			"[line 2:5] Type error at 'b': 'b' must be String but is Number."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errors := checkSource(t, test.source)
+			if strings.Join(errors, "\n") != strings.Join(test.errors, "\n") {
+				t.Fatalf("expected\n%s\ngot\n%s", strings.Join(test.errors, "\n"), strings.Join(errors, "\n"))
+			}
+		})
+	}
+}
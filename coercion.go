@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coercion decides what operators do with operands of the wrong type.
+type Coercion string
+
+const (
+	// STRICT_COERCION rejects anything but numbers for arithmetic and
+	// anything but two numbers or two strings for '+'.
+	STRICT_COERCION Coercion = "strict"
+	// LENIENT_COERCION converts numeric strings such as "2" or "1.5e3" to
+	// numbers for arithmetic, comparisons and bitwise operators, and makes
+	// '+' concatenate when either operand is a string, so "a" + 1 is "a1"
+	// and "2" + 3 is "23". Equality never converts: "1" == 1 is false.
+	LENIENT_COERCION Coercion = "lenient"
+)
+
+// coerce converts a numeric string to the number it spells in lenient mode
+// and leaves every other value alone.
+func (i *Interpreter) coerce(value interface{}) interface{} {
+	if i.coercion != LENIENT_COERCION {
+		return value
+	}
+	if text, ok := value.(string); ok {
+		if number, ok := parseNumber(text); ok {
+			return number
+		}
+	}
+	return value
+}
+
+// parseNumber reads a decimal integer or float, but not the "inf", "nan" or
+// hexadecimal forms strconv would also take.
+func parseNumber(text string) (interface{}, bool) {
+	if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return integer, true
+	}
+	if strings.Trim(text, "0123456789.eE+-") != "" {
+		return nil, false
+	}
+	if float, err := strconv.ParseFloat(text, 64); err == nil {
+		return float, true
+	}
+	return nil, false
+}
+
+// concatenate implements '+' for strings. In lenient mode one string is
+// enough and the other operand is stringified as print would.
+func (i *Interpreter) concatenate(operator Token, left interface{}, right interface{}) (interface{}, bool, error) {
+	leftStr, leftOk := left.(string)
+	rightStr, rightOk := right.(string)
+	if i.coercion == LENIENT_COERCION && leftOk != rightOk {
+		leftStr, rightStr = stringify(left), stringify(right)
+	} else if !leftOk || !rightOk {
+		return nil, false, nil
+	}
+	if err := i.allocate(len(leftStr)+len(rightStr), operator); err != nil {
+		return nil, true, err
+	}
+	return leftStr + rightStr, true, nil
+}
+
+// typeName names the type of a value in error messages.
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case GloxClass, *GloxClass:
+		return "class"
+	case GloxInstance, *GloxInstance:
+		return "instance"
+	case *GloxList:
+		return "list"
+	case *GloxGenerator:
+		return "generator"
+	case *GloxChannel:
+		return "channel"
+	case *GloxTask:
+		return "task"
+	case *GloxWaitGroup:
+		return "wait group"
+	case GloxCallable:
+		return "function"
+	case *GoObject:
+		return "Go value"
+	}
+	return fmt.Sprintf("%T", value)
+}
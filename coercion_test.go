@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func withCoercion(coercion Coercion) func(*Interpreter) {
+	return func(interpreter *Interpreter) {
+		interpreter.coercion = coercion
+	}
+}
+
+func TestStrictCoercion(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "strings", source: `print "a" + "b";`, output: "ab\n"},
+		{name: "numbers", source: "print 1 + 2.5;", output: "3.5\n"},
+		{name: "equality never converts", source: `print "1" == 1;`, output: "false\n"},
+
+		{name: "string and number", source: `print "a" + 1;`, err: "Error at '+': Operands must be two numbers or two strings, but got string and number."},
+		{name: "numeric string", source: `print "2" * 3;`, err: "Error at '*': Left operand must be a number, but got string."},
+		{name: "comparison", source: `print "2" < 3;`, err: "Error at '<': Left operand must be a number, but got string."},
+		{name: "negation", source: `print -"2";`, err: "Error at '-': Operand must be a number, but got string."},
+		{name: "nil", source: "print nil + 1;", err: "Error at '+': Operands must be two numbers or two strings, but got nil and number."},
+		{name: "boolean", source: "print true * 2;", err: "Error at '*': Left operand must be a number, but got boolean."},
+	}, withCoercion(STRICT_COERCION))
+}
+
+func TestLenientCoercion(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "concatenation", source: `print "a" + 1; print 1 + "a"; print "x" + nil; print "2" + 3;`, output: "a1\n1a\nx<nil>\n23\n"},
+		{name: "arithmetic", source: `print "2" * 3; print "1.5e3" - 500; print "7" ~/ "2"; print -"4";`, output: "6\n1000\n3\n-4\n"},
+		{name: "comparison", source: `print "10" > 9; print "2" <= "10";`, output: "true\ntrue\n"},
+		{name: "bitwise", source: `print "6" & 3; print ~"0";`, output: "2\n-1\n"},
+		{name: "equality never converts", source: `print "1" == 1; print "1" != 1;`, output: "false\ntrue\n"},
+		{name: "compound assignment", source: `var s = "n"; s += 1; print s; var n = "4"; n *= 2; print n;`, output: "n1\n8\n"},
+
+		{name: "non-numeric string", source: `print "abc" * 2;`, err: "Error at '*': Left operand must be a number, but got string."},
+		{name: "no special floats", source: `print "inf" - 1;`, err: "Left operand must be a number, but got string."},
+		{name: "no hexadecimal", source: `print "0x10" - 1;`, err: "Left operand must be a number, but got string."},
+		{name: "nil in arithmetic", source: "print nil - 1;", err: "Error at '-': Left operand must be a number, but got nil."},
+		{name: "two non-strings", source: "print true + 1;", err: "Error at '+': Operands must be two numbers or two strings, but got boolean and number."},
+	}, withCoercion(LENIENT_COERCION))
+}
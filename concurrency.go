@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// fork makes the Interpreter a spawned call runs on. It shares the globals,
+// the output and the context with i, and starts from a copy of the resolved
+// locals so that resolving more code in i doesn't race with it. Limits
+// apply to each goroutine on its own, and tracers only follow the goroutine
+// they were added to.
+func (i *Interpreter) fork() *Interpreter {
+	locals := make(map[Expr]int, len(i.locals))
+	for expr, depth := range i.locals {
+		locals[expr] = depth
+	}
+	return &Interpreter{
+		globals:     i.globals,
+		environment: i.globals,
+		locals:      locals,
+		stdin:       i.stdin,
+		stdout:      i.stdout,
+		stderr:      i.stderr,
+		output:      i.output,
+		limits:      i.limits,
+		ctx:         i.ctx,
+		coercion:    i.coercion,
+		taskFailed:  i.taskFailed,
+		tasks:       i.tasks,
+	}
+}
+
+// done returns what receives once the interpreter's context is done, or
+// nil, which never receives, when it has none.
+func (i *Interpreter) done() <-chan struct{} {
+	if i.ctx == nil {
+		return nil
+	}
+	return i.ctx.Done()
+}
+
+// GloxTask is what spawn returns. wait() blocks until the spawned call has
+// finished and returns its result. A call that fails reports its error like
+// a script would and its task returns nil; the script then exits with the
+// status of a runtime error. A script that ends before its spawned calls
+// waits for them before it exits.
+type GloxTask struct {
+	name     string
+	finished chan struct{}
+	value    interface{}
+}
+
+func (i *Interpreter) spawn(function GloxCallable, expr ExprCall, arguments []interface{}) *GloxTask {
+	task := &GloxTask{name: calleeName(function, expr), finished: make(chan struct{})}
+	fork := i.fork()
+	i.tasks.Add(1)
+	go func() {
+		defer i.tasks.Done()
+		defer close(task.finished)
+		value, err := fork.call(function, expr, arguments)
+		if err != nil {
+			fork.reportError(err)
+			fork.taskFailed.Store(true)
+			return
+		}
+		task.value = value
+	}()
+	return task
+}
+
+// waitForTasks blocks until every call spawned from i or its forks has
+// finished. Calls blocked on a channel, a wait group or a task stop once the
+// context they run with is done.
+func (i *Interpreter) waitForTasks() {
+	i.tasks.Wait()
+}
+
+func (t *GloxTask) String() string {
+	return "<task " + t.name + ">"
+}
+
+func (t *GloxTask) Get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "wait":
+		return &nativeMethod{name: name, signature: fixedSignature("wait", 0), fn: func(interpreter *Interpreter, _ []interface{}) (interface{}, error) {
+			select {
+			case <-t.finished:
+				return t.value, nil
+			case <-interpreter.done():
+				return nil, interpreter.checkCancelled(name)
+			}
+		}}, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+// GloxChannel passes values between spawned calls. Receiving from a closed
+// channel gives nil once the values sent before the close are drained.
+type GloxChannel struct {
+	channel chan interface{}
+	lock    sync.Mutex
+	closed  bool
+}
+
+// ChannelNative is the Channel(capacity) global that makes channels. A
+// channel without a capacity blocks every send until it is received.
+type ChannelNative struct{}
+
+// maxChannelCapacity keeps a channel's buffer, and the size it's counted as
+// against the allocation limit, within what Go can make.
+const maxChannelCapacity = math.MaxInt32 / elementSize
+
+func (c ChannelNative) Signature() Signature {
+	return Signature{Name: "Channel", Params: []string{"capacity"}, MinArity: 0, MaxArity: 1}
+}
+
+func (c ChannelNative) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return c.callAt(interpreter, Token{TokenType: IDENTIFIER, Lexeme: "Channel"}, arguments)
+}
+
+func (c ChannelNative) callAt(interpreter *Interpreter, paren Token, arguments []interface{}) (interface{}, error) {
+	var capacity int64
+	if len(arguments) > 0 {
+		switch value := arguments[0].(type) {
+		case int64:
+			capacity = value
+		case omitted:
+		default:
+			return nil, &RuntimeError{token: paren, message: fmt.Sprintf("Channel capacity must be an integer, but got %s.", integerTypeName(value))}
+		}
+	}
+	if capacity < 0 {
+		return nil, &RuntimeError{token: paren, message: fmt.Sprintf("Channel capacity can't be negative, but got %d.", capacity)}
+	}
+	if capacity > maxChannelCapacity {
+		return nil, &RuntimeError{token: paren, message: fmt.Sprintf("Channel capacity can't be more than %d, but got %d.", maxChannelCapacity, capacity)}
+	}
+	if err := interpreter.allocate(elementSize*int(capacity), paren); err != nil {
+		return nil, err
+	}
+	return &GloxChannel{channel: make(chan interface{}, capacity)}, nil
+}
+
+func (c ChannelNative) String() string {
+	return "<native fn>"
+}
+
+func (c *GloxChannel) String() string {
+	return fmt.Sprintf("<channel %d/%d>", len(c.channel), cap(c.channel))
+}
+
+func (c *GloxChannel) Get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "send":
+		return &nativeMethod{name: name, signature: fixedSignature("send", 1), fn: func(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+			return nil, c.send(interpreter, name, arguments[0])
+		}}, nil
+	case "recv":
+		return &nativeMethod{name: name, signature: fixedSignature("recv", 0), fn: func(interpreter *Interpreter, _ []interface{}) (interface{}, error) {
+			select {
+			case value := <-c.channel:
+				return value, nil
+			case <-interpreter.done():
+				return nil, interpreter.checkCancelled(name)
+			}
+		}}, nil
+	case "close":
+		return &nativeMethod{name: name, signature: fixedSignature("close", 0), fn: func(_ *Interpreter, _ []interface{}) (interface{}, error) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			if c.closed {
+				return nil, &RuntimeError{token: name, message: "Channel is already closed."}
+			}
+			c.closed = true
+			close(c.channel)
+			return nil, nil
+		}}, nil
+	case "closed":
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return c.closed, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+func (c *GloxChannel) send(interpreter *Interpreter, token Token, value interface{}) (err error) {
+	defer recoverClosedSend(token, &err)
+	select {
+	case c.channel <- value:
+		return nil
+	case <-interpreter.done():
+		return interpreter.checkCancelled(token)
+	}
+}
+
+// recoverClosedSend turns the panic of a send on a closed channel into a
+// runtime error. The channel may be closed while the send is blocked, so
+// checking first isn't enough.
+func recoverClosedSend(token Token, err *error) {
+	if recovered := recover(); recovered != nil {
+		if recovered, ok := recovered.(error); !ok || recovered.Error() != "send on closed channel" {
+			panic(recovered)
+		}
+		*err = &RuntimeError{token: token, message: "Can't send on a closed channel."}
+	}
+}
+
+// selectChannels is reflect.Select, failing instead of panicking when a
+// send case's channel is closed.
+func selectChannels(token Token, cases []reflect.SelectCase) (chosen int, received reflect.Value, err error) {
+	defer recoverClosedSend(token, &err)
+	chosen, received, _ = reflect.Select(cases)
+	return chosen, received, nil
+}
+
+// GloxWaitGroup waits for a number of spawned calls to call done().
+type GloxWaitGroup struct {
+	lock  sync.Mutex
+	count int64
+	// closed whenever count is zero, and replaced when it rises again
+	zero chan struct{}
+}
+
+// WaitGroupNative is the WaitGroup() global.
+type WaitGroupNative struct{}
+
+func (w WaitGroupNative) Signature() Signature {
+	return fixedSignature("WaitGroup", 0)
+}
+
+func (w WaitGroupNative) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	zero := make(chan struct{})
+	close(zero)
+	return &GloxWaitGroup{zero: zero}, nil
+}
+
+func (w WaitGroupNative) String() string {
+	return "<native fn>"
+}
+
+func (w *GloxWaitGroup) String() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return fmt.Sprintf("<wait group %d>", w.count)
+}
+
+func (w *GloxWaitGroup) Get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "add":
+		return &nativeMethod{name: name, signature: fixedSignature("add", 1), fn: func(_ *Interpreter, arguments []interface{}) (interface{}, error) {
+			delta, ok := arguments[0].(int64)
+			if !ok {
+				return nil, &RuntimeError{token: name, message: fmt.Sprintf("Wait group delta must be an integer, but got %s.", integerTypeName(arguments[0]))}
+			}
+			return nil, w.add(name, delta)
+		}}, nil
+	case "done":
+		return &nativeMethod{name: name, signature: fixedSignature("done", 0), fn: func(_ *Interpreter, _ []interface{}) (interface{}, error) {
+			return nil, w.add(name, -1)
+		}}, nil
+	case "wait":
+		return &nativeMethod{name: name, signature: fixedSignature("wait", 0), fn: func(interpreter *Interpreter, _ []interface{}) (interface{}, error) {
+			w.lock.Lock()
+			zero := w.zero
+			w.lock.Unlock()
+			select {
+			case <-zero:
+				return nil, nil
+			case <-interpreter.done():
+				return nil, interpreter.checkCancelled(name)
+			}
+		}}, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+func (w *GloxWaitGroup) add(token Token, delta int64) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.count+delta < 0 {
+		return &RuntimeError{token: token, message: "Wait group counter can't go below zero."}
+	}
+	if w.count == 0 && delta > 0 {
+		w.zero = make(chan struct{})
+	}
+	w.count += delta
+	if w.count == 0 && delta < 0 {
+		close(w.zero)
+	}
+	return nil
+}
+
+func undefinedProperty(name Token) error {
+	return &RuntimeError{
+		token:   name,
+		message: fmt.Sprintf("Undefined propety '%v'", name.Lexeme),
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConcurrency(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "spawn and wait", source: "fun sq(x) { return x * x; } var t = spawn sq(4); print t; print t.wait(); print t.wait();", output: "<task sq>\n16\n16\n"},
+		{name: "unbuffered channel", source: "var c = Channel(); fun w(n) { c.send(n * 2); } spawn w(3); print c.recv();", output: "6\n"},
+		{name: "buffered channel", source: "var c = Channel(2); c.send(1); c.send(2); print c; print c.recv(); print c.recv(); print c;",
+			output: "<channel 2/2>\n1\n2\n<channel 0/2>\n"},
+		{name: "drained after close", source: "var c = Channel(1); c.send(1); print c.closed; c.close(); print c.closed; print c.recv(); print c.recv();",
+			output: "false\ntrue\n1\n<nil>\n"},
+		{name: "wait group", source: "var wg = WaitGroup(); var c = Channel(3);\nfun job(n) { c.send(n); wg.done(); }\nwg.add(3); spawn job(1); spawn job(2); spawn job(3); wg.wait();\nprint wg; print c.recv() + c.recv() + c.recv();",
+			output: "<wait group 0>\n6\n"},
+		{name: "wait group reused", source: "var wg = WaitGroup(); wg.wait(); wg.add(1); print wg; wg.done(); wg.wait(); print wg;", output: "<wait group 1>\n<wait group 0>\n"},
+		{name: "fan out", source: "var results = Channel(10);\nfun work(n) { results.send(n * n); }\nvar tasks = [];\nfor (var i = 1; i <= 10; i++) tasks.push(spawn work(i));\nfor (var t in tasks) t.wait();\nvar sum = 0;\nfor (var i = 0; i < 10; i++) sum += results.recv();\nprint sum;",
+			output: "385\n"},
+		{name: "select default", source: `var c = Channel(); select { case c.recv() => print "got"; default => print "nothing"; }`, output: "nothing\n"},
+		{name: "select receives", source: `var c = Channel(1); c.send(5); select { case var v = c.recv() => print v; default => print "none"; }`, output: "5\n"},
+		{name: "select sends", source: `var c = Channel(1); select { case c.send(7) => print "sent"; } print c.recv();`, output: "sent\n7\n"},
+		{name: "select waits for a task", source: "var a = Channel(); var b = Channel();\nfun w() { b.send(\"b\"); }\nspawn w();\nselect { case var v = a.recv() => print v; case var v = b.recv() => print v; }",
+			output: "b\n"},
+		{name: "generator shared by tasks", source: "fun count(n) { for (var i = 0; i < n; i++) yield i; }\nvar g = count(100); var sums = Channel(4);\nfun take() { var sum = 0; for (var i = 0; i < 25; i++) sum += g.next(); sums.send(sum); }\nfor (var i = 0; i < 4; i++) spawn take();\nprint sums.recv() + sums.recv() + sums.recv() + sums.recv(); print g.next(); print g.done;",
+			output: "4950\n<nil>\ntrue\n"},
+		{name: "select on a closed channel", source: "var c = Channel(1); c.close(); select { case var v = c.recv() => print v; }", output: "<nil>\n"},
+
+		{name: "float capacity", source: "Channel(1.5);", err: "Error at ')': Channel capacity must be an integer, but got float."},
+		{name: "string capacity", source: `Channel("a");`, err: "Error at ')': Channel capacity must be an integer, but got string."},
+		{name: "negative capacity", source: "Channel(-1);", err: "Error at ')': Channel capacity can't be negative, but got -1."},
+		{name: "huge capacity", source: "Channel(9223372036854775807);", err: "Error at ')': Channel capacity can't be more than 134217727, but got 9223372036854775807."},
+		{name: "capacity past the maximum", source: "Channel(134217728);", err: "Error at ')': Channel capacity can't be more than 134217727, but got 134217728."},
+		{name: "closed twice", source: "var c = Channel(1); c.close(); c.close();", err: "Error at 'close': Channel is already closed."},
+		{name: "send on closed", source: "var c = Channel(1); c.close(); c.send(1);", err: "Error at 'send': Can't send on a closed channel."},
+		{name: "select send on closed", source: "var c = Channel(1); c.close(); select { case c.send(1) => print 1; }", err: "Error at 'select': Can't send on a closed channel."},
+		{name: "select on a number", source: "var x = 1; select { case x.recv() => print 1; }", err: "Error at 'recv': Can only select on channels, but got number."},
+		{name: "float delta", source: "var w = WaitGroup(); w.add(0.5);", err: "Error at 'add': Wait group delta must be an integer, but got float."},
+		{name: "negative counter", source: "var w = WaitGroup(); w.done();", err: "Error at 'done': Wait group counter can't go below zero."},
+		{name: "unknown channel property", source: "var c = Channel(); c.foo;", err: "Error at 'foo': Undefined propety 'foo'"},
+		{name: "unknown task property", source: "fun f() {} var t = spawn f(); t.result;", err: "Error at 'result': Undefined propety 'result'"},
+		{name: "spawn a non-callable", source: "var x = 1; spawn x();", err: "Can only call functions and classes"},
+	})
+}
+
+func TestChannelAllocation(t *testing.T) {
+	_, err := runSource(t, "var small = Channel(10);\nvar large = Channel(134217727);", withLimits(Limits{MaxAllocation: 1000}))
+	var limitError *LimitError
+	if !errors.As(err, &limitError) || limitError.Kind != ALLOCATION_LIMIT || !strings.HasPrefix(err.Error(), "[line 2]") {
+		t.Fatalf("expected the large buffer to exceed the allocation limit, got %v", err)
+	}
+}
+
+func TestConcurrencySyntaxErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"spawn 1;", "Error at 'spawn': Expect a call after 'spawn'."},
+		{"select { case 1 => print 1; }", "Error at 'case': Expect a channel's recv() or send(value) in select case."},
+		{"var c; select { case var v = c.send(1) => print 1; }", "Error at 'case': Expect a channel's recv() or send(value) in select case."},
+		{"var c; select { case c?.recv() => print 1; }", "Error at 'case': Expect a channel's recv() or send(value) in select case."},
+		{"var c; select { default => print 1; case c.recv() => print 2; }", "Error at 'case': Default must be the last case."},
+		{"select { print 1; }", "Error at 'print': Expect 'case' or 'default'."},
+		{"var c; select { case c.recv() print 1; }", "Error at 'print': Expect '=>' after select case."},
+	}
+	for _, test := range tests {
+		errors := parseErrors(test.source)
+		if len(errors) == 0 || !strings.Contains(errors[0], test.err) {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, errors)
+		}
+	}
+}
+
+func TestFailedTask(t *testing.T) {
+	var interpreter *Interpreter
+	output, err := runSource(t, `fun f() { return 1 / nil; } var t = spawn f(); print t.wait(); print "after";`, func(i *Interpreter) { interpreter = i })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "<nil>\nafter\n" {
+		t.Errorf("expected the script to go on after the task failed, got %q", output)
+	}
+	if !interpreter.taskFailed.Load() {
+		t.Error("expected the failed task to be recorded")
+	}
+}
+
+func TestBlockedUntilCancelled(t *testing.T) {
+	for _, source := range []string{
+		"var c = Channel(); c.recv();",
+		"var c = Channel(); c.send(1);",
+		"var c = Channel(); select { case c.recv() => print 1; }",
+		"var w = WaitGroup(); w.add(1); w.wait();",
+		"var c = Channel(); fun f() { c.recv(); } spawn f().wait();",
+		"var c = Channel(); fun g() { c.recv(); yield 1; } var x = g(); fun f() { x.next(); } spawn f(); x.next();",
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, _, err := runContext(t, ctx, source)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected the deadline to stop it, got %v", source, err)
+		}
+	}
+}
+
+// TestSharedState spawns writers to the same globals and fields. Run it with
+// -race to check Environment and GloxInstance are safe to share.
+func TestSharedState(t *testing.T) {
+	source := `class Box {}
+var box = Box();
+var last = nil;
+var wg = WaitGroup();
+fun write(n) {
+  for (var i = 0; i < 50; i++) {
+    box.value = n;
+    last = box.value;
+    var local = n;
+  }
+  wg.done();
+}
+wg.add(8);
+for (var n = 0; n < 8; n++) spawn write(n);
+wg.wait();
+print box.value != nil and last != nil;`
+	runScriptTests(t, []scriptTest{{name: "writers", source: source, output: "true\n"}})
+}
+
+func TestWaitForTasks(t *testing.T) {
+	var stdout bytes.Buffer
+	var interpreter *Interpreter
+	gate := make(chan struct{})
+	setup := func(i *Interpreter) {
+		interpreter = i
+		i.setStreams(strings.NewReader(""), &stdout, &stdout)
+		if err := i.defineGo("gate", func() { <-gate }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := runSource(t, `fun late() { gate(); print "late"; return 1 / nil; } spawn late(); print "main";`, setup); err != nil {
+		t.Fatal(err)
+	}
+	if interpreter.taskFailed.Load() {
+		t.Fatal("expected the task to still be running")
+	}
+	close(gate)
+	interpreter.waitForTasks()
+	interpreter.flush()
+	if !strings.HasPrefix(stdout.String(), "main\nlate\n") || !strings.Contains(stdout.String(), "Right operand must be a number, but got nil.") {
+		t.Errorf("expected the late task's output and error, got %q", stdout.String())
+	}
+	if !interpreter.taskFailed.Load() {
+		t.Error("expected the late task's failure to be recorded")
+	}
+}
+
+func TestWaitForTasksCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, output, err := runContext(t, ctx, "var c = Channel(); fun f() { c.recv(); } spawn f();")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected waiting to last until the deadline")
+	}
+	if !strings.Contains(output, "[line 1] Error: script stopped: context deadline exceeded.") {
+		t.Errorf("expected the task to report being stopped, got %q", output)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type coverageBranch struct {
+	token Token
+	// hits of the true and the false outcome of the condition
+	taken [2]int
+}
+
+// Coverage is a Tracer recording which lines ran and which way every
+// condition went. The executable lines and branch points are collected
+// from the AST up front so that code that never ran is reported too.
+type Coverage struct {
+	path     string
+	source   []string
+	lines    map[int]int
+	branches map[Token]*coverageBranch
+}
+
+func NewCoverage(interpreter *Interpreter, statements []*Stmt, path string, source string) *Coverage {
+	coverage := &Coverage{
+		path:     path,
+		source:   strings.Split(source, "\n"),
+		lines:    make(map[int]int),
+		branches: make(map[Token]*coverageBranch),
+	}
+	coverage.collectStatements(statements)
+	interpreter.tracers = append(interpreter.tracers, coverage)
+	return coverage
+}
+
+func (c *Coverage) collectStatements(statements []*Stmt) {
+	for _, stmt := range statements {
+		c.collectStatement(*stmt)
+	}
+}
+
+func (c *Coverage) collectStatement(stmt Stmt) {
+	if stmt == nil {
+		return
+	}
+	if _, ok := stmt.(StmtBlock); !ok {
+		if line := stmtLine(stmt); line > 0 {
+			c.lines[line] += 0
+		}
+	}
+	switch s := stmt.(type) {
+	case StmtVarDeclaration:
+		if s.Initializer != nil {
+			c.collectExpr(*s.Initializer)
+		}
+	case StmtExpression:
+		c.collectExpr(*s.Expression)
+	case StmtPrint:
+		c.collectExpr(s.Expression)
+	case StmtBlock:
+		c.collectStatements(s.Statements)
+	case StmtIf:
+		c.addBranch(s.Keyword)
+		c.collectExpr(s.Condition)
+		c.collectStatement(s.ThenBranch)
+		c.collectStatement(s.ElseBranch)
+	case StmtWhile:
+		c.addBranch(s.Keyword)
+		c.collectExpr(s.Condition)
+		c.collectStatement(s.Body)
+	case StmtMatch:
+		c.collectExpr(s.Subject)
+		for _, matchCase := range s.Cases {
+			c.addBranch(matchCase.Keyword)
+			c.collectStatement(matchCase.Body)
+		}
+	case StmtDestructure:
+		c.collectExpr(s.Initializer)
+	case StmtYield:
+		if s.Value != nil {
+			c.collectExpr(s.Value)
+		}
+	case StmtSelect:
+		for _, selectCase := range s.Cases {
+			c.addBranch(selectCase.Keyword)
+			if selectCase.Channel != nil {
+				c.collectExpr(selectCase.Channel)
+			}
+			if selectCase.Value != nil {
+				c.collectExpr(selectCase.Value)
+			}
+			c.collectStatement(selectCase.Body)
+		}
+	case StmtForIn:
+		c.addBranch(s.Keyword)
+		c.collectExpr(s.Iterable)
+		c.collectStatement(s.Body)
+	case StmtFunction:
+		c.collectFunction(s)
+	case StmtReturn:
+		if s.Value != nil {
+			c.collectExpr(s.Value)
+		}
+	case StmtClass:
+		for _, method := range s.Methods {
+			c.collectFunction(method.(StmtFunction))
+		}
+	}
+}
+
+func (c *Coverage) collectFunction(function StmtFunction) {
+	for _, value := range function.Defaults {
+		if value != nil {
+			c.collectExpr(*value)
+		}
+	}
+	c.collectStatements(function.Body)
+}
+
+func (c *Coverage) collectExpr(expr Expr) {
+	switch e := expr.(type) {
+	case ExprBinary:
+		c.collectExpr(e.Left)
+		c.collectExpr(e.Right)
+	case ExprGrouping:
+		c.collectExpr(*e.Expression)
+	case ExprUnary:
+		c.collectExpr(*e.Right)
+	case ExprLogical:
+		c.addBranch(e.Operator)
+		c.collectExpr(*e.Left)
+		c.collectExpr(*e.Right)
+	case ExprAssign:
+		c.collectExpr(*e.Value)
+	case ExprCall:
+		c.collectExpr(*e.Callee)
+		for _, argument := range e.Arguments {
+			c.collectExpr(*argument)
+		}
+	case ExprGet:
+		c.collectExpr(*e.Object)
+	case ExprSet:
+		c.collectExpr(*e.Object)
+		c.collectExpr(*e.Value)
+	case ExprIndex:
+		c.collectExpr(*e.Object)
+		c.collectExpr(*e.Index)
+	case ExprIndexSet:
+		c.collectExpr(*e.Object)
+		c.collectExpr(*e.Index)
+		c.collectExpr(*e.Value)
+	case ExprInterpolation:
+		for _, part := range e.Parts {
+			c.collectExpr(*part)
+		}
+	case ExprList:
+		for _, element := range e.Elements {
+			c.collectExpr(*element)
+		}
+	case ExprDestructure:
+		c.collectExpr(*e.Value)
+	case ExprSpawn:
+		c.collectExpr(e.Call)
+	case ExprConditional:
+		c.addBranch(e.Question)
+		c.collectExpr(*e.Condition)
+		c.collectExpr(*e.Then)
+		c.collectExpr(*e.Else)
+	case ExprUpdate:
+		c.collectExpr(e.Target)
+		c.collectExpr(*e.Value)
+	}
+}
+
+func (c *Coverage) addBranch(token Token) {
+	c.branches[token] = &coverageBranch{token: token}
+}
+
+func (c *Coverage) statement(stmt Stmt) error {
+	if _, ok := stmt.(StmtBlock); ok {
+		return nil
+	}
+	if line := stmtLine(stmt); line > 0 {
+		c.lines[line]++
+	}
+	return nil
+}
+
+func (c *Coverage) call(callee GloxCallable, expr ExprCall) error {
+	return nil
+}
+
+func (c *Coverage) ret(callee GloxCallable, expr ExprCall) {}
+
+func (c *Coverage) branch(token Token, taken bool) {
+	branch, ok := c.branches[token]
+	if !ok {
+		return
+	}
+	if taken {
+		branch.taken[0]++
+	} else {
+		branch.taken[1]++
+	}
+}
+
+func (c *Coverage) sortedLines() []int {
+	var lines []int
+	for line := range c.lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func (c *Coverage) sortedBranches() []*coverageBranch {
+	var branches []*coverageBranch
+	for _, branch := range c.branches {
+		branches = append(branches, branch)
+	}
+	sort.Slice(branches, func(a, b int) bool {
+		if branches[a].token.Line != branches[b].token.Line {
+			return branches[a].token.Line < branches[b].token.Line
+		}
+		return branches[a].token.Column < branches[b].token.Column
+	})
+	return branches
+}
+
+func (c *Coverage) totals() (linesHit int, branchesHit int) {
+	for _, hits := range c.lines {
+		if hits > 0 {
+			linesHit++
+		}
+	}
+	for _, branch := range c.branches {
+		for _, taken := range branch.taken {
+			if taken > 0 {
+				branchesHit++
+			}
+		}
+	}
+	return linesHit, branchesHit
+}
+
+func percent(hit int, found int) float64 {
+	if found == 0 {
+		return 100
+	}
+	return 100 * float64(hit) / float64(found)
+}
+
+func (c *Coverage) writeSummary(out io.Writer) {
+	linesHit, branchesHit := c.totals()
+	branchesFound := 2 * len(c.branches)
+	fmt.Fprintf(out, "Coverage for %s\n", c.path)
+	fmt.Fprintf(out, "  Lines:    %d/%d (%.1f%%)\n", linesHit, len(c.lines), percent(linesHit, len(c.lines)))
+	fmt.Fprintf(out, "  Branches: %d/%d (%.1f%%)\n", branchesHit, branchesFound, percent(branchesHit, branchesFound))
+}
+
+// writeListing prints the source with each executable line prefixed by its
+// hit count, or ##### when it never ran, followed by the outcomes of the
+// conditions on it.
+func (c *Coverage) writeListing(out io.Writer) {
+	branchesByLine := make(map[int][]*coverageBranch)
+	for _, branch := range c.sortedBranches() {
+		branchesByLine[branch.token.Line] = append(branchesByLine[branch.token.Line], branch)
+	}
+	for index, text := range c.source {
+		line := index + 1
+		count := "-"
+		if hits, ok := c.lines[line]; ok {
+			count = fmt.Sprint(hits)
+			if hits == 0 {
+				count = "#####"
+			}
+		}
+		fmt.Fprintf(out, "%8s: %4d: %s", count, line, text)
+		for _, branch := range branchesByLine[line] {
+			fmt.Fprintf(out, "  [%s: true %d, false %d]", branch.token.Lexeme, branch.taken[0], branch.taken[1])
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func (c *Coverage) writeLcov(out io.Writer) {
+	fmt.Fprintln(out, "TN:")
+	fmt.Fprintf(out, "SF:%s\n", c.path)
+	for block, branch := range c.sortedBranches() {
+		for outcome, taken := range branch.taken {
+			count := fmt.Sprint(taken)
+			if branch.taken[0]+branch.taken[1] == 0 {
+				count = "-"
+			}
+			fmt.Fprintf(out, "BRDA:%d,%d,%d,%s\n", branch.token.Line, block, outcome, count)
+		}
+	}
+	linesHit, branchesHit := c.totals()
+	fmt.Fprintf(out, "BRF:%d\n", 2*len(c.branches))
+	fmt.Fprintf(out, "BRH:%d\n", branchesHit)
+	for _, line := range c.sortedLines() {
+		fmt.Fprintf(out, "DA:%d,%d\n", line, c.lines[line])
+	}
+	fmt.Fprintf(out, "LF:%d\n", len(c.lines))
+	fmt.Fprintf(out, "LH:%d\n", linesHit)
+	fmt.Fprintln(out, "end_of_record")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const coverageTestSource = `fun sign(x) {
+  if (x > 0) return 1;
+  if (x < 0) return -1;
+  return 0;
+}
+var i = 0;
+while (i < 2) i = i + 1;
+print sign(i) > 0 and sign(-i) < 0;
+var never = i > 5 ? sign(0) : nil;
+`
+
+func coverSource(t *testing.T, source string) *Coverage {
+	t.Helper()
+	statements := parseSource(t, source)
+	var output bytes.Buffer
+	interpreter := NewInterpreter()
+	interpreter.setStreams(strings.NewReader(""), &output, &output)
+	resolver := NewResolver(interpreter)
+	if err := resolver.resolveStatements(statements); err != nil {
+		t.Fatal(err)
+	}
+	coverage := NewCoverage(interpreter, statements, "test.glox", source)
+	if _, err := interpreter.interpret(statements); err != nil {
+		t.Fatal(err)
+	}
+	return coverage
+}
+
+func TestCoverageLcov(t *testing.T) {
+	coverage := coverSource(t, coverageTestSource)
+	var lcov bytes.Buffer
+	coverage.writeLcov(&lcov)
+	expected := `TN:
+SF:test.glox
+BRDA:2,0,0,1
+BRDA:2,0,1,1
+BRDA:3,1,0,1
+BRDA:3,1,1,0
+BRDA:7,2,0,2
+BRDA:7,2,1,1
+BRDA:8,3,0,1
+BRDA:8,3,1,0
+BRDA:9,4,0,0
+BRDA:9,4,1,1
+BRF:10
+BRH:7
+DA:1,1
+DA:2,3
+DA:3,2
+DA:4,0
+DA:6,1
+DA:7,3
+DA:8,1
+DA:9,1
+LF:8
+LH:7
+end_of_record
+`
+	if lcov.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, lcov.String())
+	}
+}
+
+func TestCoverageListing(t *testing.T) {
+	coverage := coverSource(t, coverageTestSource)
+	var summary, listing bytes.Buffer
+	coverage.writeSummary(&summary)
+	coverage.writeListing(&listing)
+	if expected := "Coverage for test.glox\n  Lines:    7/8 (87.5%)\n  Branches: 7/10 (70.0%)\n"; summary.String() != expected {
+		t.Errorf("expected summary\n%s\ngot\n%s", expected, summary.String())
+	}
+	lines := strings.Split(listing.String(), "\n")
+	expected := map[int]string{
+		2: "       3:    2:   if (x > 0) return 1;  [if: true 1, false 1]",
+		4: "   #####:    4:   return 0;",
+		5: "       -:    5: }",
+		9: "       1:    9: var never = i > 5 ? sign(0) : nil;  [?: true 0, false 1]",
+	}
+	for line, text := range expected {
+		if lines[line-1] != text {
+			t.Errorf("expected line %d as\n%q\ngot\n%q", line, text, lines[line-1])
+		}
+	}
+}
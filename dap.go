@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// DapServer speaks the Debug Adapter Protocol over a pair of streams. The
+// script runs on its own goroutine; while it is stopped, requests that need
+// its state are handed to it as closures through commands.
+type DapServer struct {
+	in          *bufio.Reader
+	out         io.Writer
+	writeMutex  sync.Mutex
+	seq         int
+	interpreter *Interpreter
+	debugger    *Debugger
+	path        string
+	statements  []*Stmt
+	launched    bool
+	configured  bool
+	started     bool
+	finished    chan struct{}
+
+	// commands returning true resume the script
+	commands   chan func() bool
+	stateMutex sync.Mutex
+	paused     bool
+	// variable references handed out since the last stop; only touched on
+	// the interpreter goroutine
+	references []func() []dapVariable
+}
+
+func NewDapServer(in io.Reader, out io.Writer) *DapServer {
+	server := &DapServer{
+		in:       bufio.NewReader(in),
+		out:      out,
+		commands: make(chan func() bool),
+		finished: make(chan struct{}),
+	}
+	server.interpreter = NewInterpreter()
+	server.interpreter.setStreams(
+		strings.NewReader(""),
+		dapOutput{server: server, category: "stdout"},
+		dapOutput{server: server, category: "stderr"},
+	)
+	server.debugger = NewDebugger(server.interpreter, server, false)
+	return server
+}
+
+type dapOutput struct {
+	server   *DapServer
+	category string
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.server.event("output", map[string]interface{}{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+func (s *DapServer) send(message map[string]interface{}) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.seq++
+	message["seq"] = s.seq
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	writeMessage(s.out, body)
+}
+
+func (s *DapServer) respond(request *dapRequest, body interface{}, err error) {
+	message := map[string]interface{}{
+		"type":        "response",
+		"request_seq": request.Seq,
+		"command":     request.Command,
+		"success":     err == nil,
+	}
+	if err != nil {
+		message["message"] = err.Error()
+	} else if body != nil {
+		message["body"] = body
+	}
+	s.send(message)
+}
+
+func (s *DapServer) event(name string, body interface{}) {
+	message := map[string]interface{}{"type": "event", "event": name}
+	if body != nil {
+		message["body"] = body
+	}
+	s.send(message)
+}
+
+// serve handles requests until the client disconnects or closes the stream.
+func (s *DapServer) serve() int {
+	for {
+		body, err := readMessage(s.in)
+		if err != nil {
+			s.debugger.terminate()
+			return 0
+		}
+		var request dapRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			continue
+		}
+		if !s.handle(&request) {
+			return 0
+		}
+	}
+}
+
+func (s *DapServer) handle(request *dapRequest) bool {
+	switch request.Command {
+	case "initialize":
+		s.respond(request, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil)
+		s.event("initialized", nil)
+	case "launch":
+		var arguments struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		json.Unmarshal(request.Arguments, &arguments)
+		err := s.load(arguments.Program)
+		if err == nil && arguments.StopOnEntry {
+			s.debugger.entry = true
+			s.debugger.mode = STEP_IN
+		}
+		s.respond(request, nil, err)
+		if err == nil {
+			s.launched = true
+			s.start()
+		}
+	case "setBreakpoints":
+		var arguments struct {
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+		json.Unmarshal(request.Arguments, &arguments)
+		var lines []int
+		breakpoints := []map[string]interface{}{}
+		for _, breakpoint := range arguments.Breakpoints {
+			lines = append(lines, breakpoint.Line)
+			breakpoints = append(breakpoints, map[string]interface{}{"verified": true, "line": breakpoint.Line})
+		}
+		s.debugger.setBreakpoints(lines)
+		s.respond(request, map[string]interface{}{"breakpoints": breakpoints}, nil)
+	case "configurationDone":
+		s.respond(request, nil, nil)
+		s.configured = true
+		s.start()
+	case "threads":
+		s.respond(request, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": 1, "name": "main"}},
+		}, nil)
+	case "stackTrace":
+		s.whilePaused(request, func() (interface{}, error) {
+			frames := []map[string]interface{}{}
+			for index := len(s.debugger.frames) - 1; index >= 0; index-- {
+				frame := s.debugger.frames[index]
+				frames = append(frames, map[string]interface{}{
+					"id":     index,
+					"name":   frame.Name,
+					"line":   frame.Line,
+					"column": 1,
+					"source": map[string]string{"path": s.path},
+				})
+			}
+			return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+		})
+	case "scopes":
+		var arguments struct {
+			FrameID int `json:"frameId"`
+		}
+		json.Unmarshal(request.Arguments, &arguments)
+		s.whilePaused(request, func() (interface{}, error) {
+			if arguments.FrameID < 0 || arguments.FrameID >= len(s.debugger.frames) {
+				return nil, fmt.Errorf("Unknown frame %d.", arguments.FrameID)
+			}
+			scopes := []map[string]interface{}{}
+			chain := s.debugger.environments(arguments.FrameID)
+			for depth, environment := range chain {
+				name := "Locals"
+				if depth == len(chain)-1 {
+					name = "Globals"
+				} else if depth > 0 {
+					name = fmt.Sprintf("Enclosing %d", depth)
+				}
+				scopes = append(scopes, map[string]interface{}{
+					"name":               name,
+					"variablesReference": s.environmentReference(environment),
+					"expensive":          depth == len(chain)-1,
+				})
+			}
+			return map[string]interface{}{"scopes": scopes}, nil
+		})
+	case "variables":
+		var arguments struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		json.Unmarshal(request.Arguments, &arguments)
+		s.whilePaused(request, func() (interface{}, error) {
+			index := arguments.VariablesReference - 1
+			if index < 0 || index >= len(s.references) {
+				return nil, fmt.Errorf("Unknown variables reference %d.", arguments.VariablesReference)
+			}
+			return map[string]interface{}{"variables": s.references[index]()}, nil
+		})
+	case "evaluate":
+		var arguments struct {
+			Expression string `json:"expression"`
+			FrameID    *int   `json:"frameId"`
+		}
+		json.Unmarshal(request.Arguments, &arguments)
+		s.whilePaused(request, func() (interface{}, error) {
+			frame := len(s.debugger.frames) - 1
+			if arguments.FrameID != nil && *arguments.FrameID >= 0 && *arguments.FrameID <= frame {
+				frame = *arguments.FrameID
+			}
+			value, err := s.debugger.evaluate(arguments.Expression, frame)
+			if err != nil {
+				return nil, err
+			}
+			variable := s.variable("", value)
+			return map[string]interface{}{"result": variable.Value, "variablesReference": variable.VariablesReference}, nil
+		})
+	case "continue", "next", "stepIn", "stepOut":
+		mode := map[string]StepMode{"continue": STEP_CONTINUE, "next": STEP_OVER, "stepIn": STEP_IN, "stepOut": STEP_OUT}[request.Command]
+		s.resume(request, func() { s.debugger.resume(mode) })
+	case "pause":
+		s.debugger.requestPause()
+		s.respond(request, nil, nil)
+	case "disconnect", "terminate":
+		s.debugger.terminate()
+		if s.isPaused() {
+			s.commands <- func() bool { return true }
+		}
+		if s.started {
+			<-s.finished
+		}
+		s.respond(request, nil, nil)
+		if request.Command == "disconnect" {
+			return false
+		}
+	default:
+		s.respond(request, nil, fmt.Errorf("Unsupported request '%s'.", request.Command))
+	}
+	return true
+}
+
+func (s *DapServer) load(path string) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scanner := NewScanner(string(source))
+	tokens := scanner.scanTokens()
+	if len(scanner.errors) > 0 {
+		return scanner.errors[0]
+	}
+	parser := NewParser(tokens)
+	statements, errors := parser.parse()
+	if len(errors) > 0 {
+		return errors[0]
+	}
+	resolver := NewResolver(s.interpreter)
+	if err := resolver.resolveStatements(statements); err != nil {
+		return err
+	}
+	checker := NewChecker(&resolver)
+	if typeErrors := checker.check(statements); len(typeErrors) > 0 {
+		return typeErrors[0]
+	}
+	s.path = path
+	s.statements = statements
+	return nil
+}
+
+// start runs the script once it is loaded and the client has finished
+// configuring breakpoints.
+func (s *DapServer) start() {
+	if !s.launched || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	go func() {
+		defer close(s.finished)
+		exitCode := 0
+		if _, err := s.interpreter.interpret(s.statements); err != nil {
+			if _, ok := err.(DebugTerminated); !ok {
+				s.interpreter.reportError(err)
+				exitCode = 70
+			}
+		}
+		s.interpreter.flush()
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+func (s *DapServer) isPaused() bool {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.paused
+}
+
+// whilePaused answers a request on the interpreter goroutine.
+func (s *DapServer) whilePaused(request *dapRequest, query func() (interface{}, error)) {
+	if !s.isPaused() {
+		s.respond(request, nil, fmt.Errorf("The script is running."))
+		return
+	}
+	done := make(chan struct{})
+	s.commands <- func() bool {
+		body, err := query()
+		s.respond(request, body, err)
+		close(done)
+		return false
+	}
+	<-done
+}
+
+func (s *DapServer) resume(request *dapRequest, step func()) {
+	if !s.isPaused() {
+		s.respond(request, nil, fmt.Errorf("The script is running."))
+		return
+	}
+	done := make(chan struct{})
+	s.commands <- func() bool {
+		step()
+		s.respond(request, map[string]interface{}{"allThreadsContinued": true}, nil)
+		close(done)
+		return true
+	}
+	<-done
+}
+
+func (s *DapServer) stopped(debugger *Debugger, reason string) error {
+	s.stateMutex.Lock()
+	s.paused = true
+	s.stateMutex.Unlock()
+	s.event("stopped", map[string]interface{}{"reason": reason, "threadId": 1, "allThreadsStopped": true})
+	for command := range s.commands {
+		if command() {
+			break
+		}
+	}
+	s.stateMutex.Lock()
+	s.paused = false
+	s.stateMutex.Unlock()
+	s.references = nil
+	return nil
+}
+
+func (s *DapServer) environmentReference(environment *Environment) int {
+	s.references = append(s.references, func() []dapVariable {
+		variables := []dapVariable{}
+		values := environment.snapshot()
+		for _, name := range sortedNames(values) {
+			variables = append(variables, s.variable(name, values[name]))
+		}
+		return variables
+	})
+	return len(s.references)
+}
+
+// variable describes a value for the client, making instances expandable.
+func (s *DapServer) variable(name string, value interface{}) dapVariable {
+	variable := dapVariable{Name: name, Value: fmt.Sprint(value)}
+	var instance *GloxInstance
+	switch v := value.(type) {
+	case *GloxInstance:
+		instance = v
+	case GloxInstance:
+		instance = &v
+	}
+	if instance != nil {
+		s.references = append(s.references, func() []dapVariable {
+			fields := instance.fields()
+			variables := []dapVariable{}
+			for _, field := range sortedNames(fields) {
+				variables = append(variables, s.variable(field, fields[field]))
+			}
+			return variables
+		})
+		variable.VariablesReference = len(s.references)
+	}
+	return variable
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dapClient talks to a DapServer serving on its own goroutine, the way an
+// editor's debug adapter client does.
+type dapClient struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]interface{}
+	seq      int
+	// events read while waiting for something else
+	events []map[string]interface{}
+	exited chan int
+}
+
+func startDap(t *testing.T) *dapClient {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	client := &dapClient{t: t, in: clientOut, messages: make(chan map[string]interface{}, 100), exited: make(chan int, 1)}
+	go func() {
+		client.exited <- NewDapServer(serverIn, serverOut).serve()
+		serverOut.Close()
+	}()
+	go func() {
+		defer close(client.messages)
+		reader := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(reader)
+			if err != nil {
+				return
+			}
+			var message map[string]interface{}
+			if json.Unmarshal(body, &message) == nil {
+				client.messages <- message
+			}
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return client
+}
+
+func (c *dapClient) next() map[string]interface{} {
+	c.t.Helper()
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server closed the stream")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// request sends a request and returns its response, keeping the events
+// that arrive before it.
+func (c *dapClient) request(command string, arguments interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.seq++
+	body, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	writeMessage(c.in, body)
+	for {
+		message := c.next()
+		if message["type"] == "response" && int(message["request_seq"].(float64)) == c.seq {
+			return message
+		}
+		c.events = append(c.events, message)
+	}
+}
+
+// event returns the first event of that name, waiting for it if needed.
+func (c *dapClient) event(name string) map[string]interface{} {
+	c.t.Helper()
+	for index, event := range c.events {
+		if event["event"] == name {
+			c.events = append(c.events[:index], c.events[index+1:]...)
+			return event
+		}
+	}
+	for {
+		message := c.next()
+		if message["type"] == "event" && message["event"] == name {
+			return message
+		}
+		c.events = append(c.events, message)
+	}
+}
+
+func (c *dapClient) succeed(command string, arguments interface{}) map[string]interface{} {
+	c.t.Helper()
+	response := c.request(command, arguments)
+	if response["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, response["message"])
+	}
+	body, _ := response["body"].(map[string]interface{})
+	return body
+}
+
+// output joins the output events received so far.
+func (c *dapClient) output() string {
+	var text strings.Builder
+	for _, event := range c.events {
+		if event["event"] == "output" {
+			text.WriteString(event["body"].(map[string]interface{})["output"].(string))
+		}
+	}
+	return text.String()
+}
+
+func writeScript(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.glox")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDapSession(t *testing.T) {
+	path := writeScript(t, debugTestSource)
+	client := startDap(t)
+	capabilities := client.succeed("initialize", map[string]interface{}{"adapterID": "glox"})
+	if capabilities["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("unexpected capabilities %v", capabilities)
+	}
+	client.event("initialized")
+	client.succeed("launch", map[string]interface{}{"program": path})
+	breakpoints := client.succeed("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]int{{"line": 6}},
+	})
+	if verified := breakpoints["breakpoints"].([]interface{}); len(verified) != 1 {
+		t.Errorf("expected one verified breakpoint, got %v", verified)
+	}
+	client.succeed("configurationDone", nil)
+
+	stopped := client.event("stopped")
+	if reason := stopped["body"].(map[string]interface{})["reason"]; reason != "breakpoint" {
+		t.Fatalf("expected to stop on the breakpoint, got %v", reason)
+	}
+	frames := client.succeed("stackTrace", map[string]int{"threadId": 1})["stackFrames"].([]interface{})
+	var names []string
+	for _, frame := range frames {
+		frame := frame.(map[string]interface{})
+		names = append(names, frame["name"].(string)+":"+jsonString(frame["line"]))
+	}
+	if strings.Join(names, " ") != "add:6 <script>:10" {
+		t.Errorf("unexpected stack %v", names)
+	}
+	top := int(frames[0].(map[string]interface{})["id"].(float64))
+	scopes := client.succeed("scopes", map[string]int{"frameId": top})["scopes"].([]interface{})
+	locals := scopes[0].(map[string]interface{})
+	if locals["name"] != "Locals" {
+		t.Fatalf("expected the locals first, got %v", scopes)
+	}
+	variables := client.succeed("variables", map[string]interface{}{"variablesReference": locals["variablesReference"]})["variables"].([]interface{})
+	var values []string
+	for _, variable := range variables {
+		variable := variable.(map[string]interface{})
+		values = append(values, variable["name"].(string)+"="+variable["value"].(string))
+	}
+	if strings.Join(values, " ") != "a=1 b=2" {
+		t.Errorf("unexpected locals %v", values)
+	}
+	if result := client.succeed("evaluate", map[string]interface{}{"expression": "a + b", "frameId": top})["result"]; result != "3" {
+		t.Errorf("expected a + b to be 3, got %v", result)
+	}
+	if response := client.request("evaluate", map[string]interface{}{"expression": "nope"}); response["success"] != false {
+		t.Errorf("expected evaluating an undefined name to fail, got %v", response)
+	}
+
+	client.succeed("next", map[string]int{"threadId": 1})
+	client.event("stopped")
+	client.succeed("continue", map[string]int{"threadId": 1})
+	exited := client.event("exited")
+	if code := exited["body"].(map[string]interface{})["exitCode"]; code != 0.0 {
+		t.Errorf("expected exit code 0, got %v", code)
+	}
+	client.event("terminated")
+	if output := client.output(); output != "3\n6\n" {
+		t.Errorf("unexpected output %q", output)
+	}
+	client.succeed("disconnect", nil)
+	if code := <-client.exited; code != 0 {
+		t.Errorf("expected the server to exit with 0, got %d", code)
+	}
+}
+
+func TestDapErrors(t *testing.T) {
+	client := startDap(t)
+	client.succeed("initialize", nil)
+	tests := []struct {
+		command   string
+		arguments interface{}
+		message   string
+	}{
+		{"launch", map[string]string{"program": filepath.Join(t.TempDir(), "missing.glox")}, "no such file"},
+		{"launch", map[string]string{"program": writeScript(t, "var = 1;")}, "Expect variable name."},
+		{"launch", map[string]string{"program": writeScript(t, "return 1;")}, "Can't return from top-level code."},
+		{"stackTrace", nil, "The script is running."},
+		{"continue", nil, "The script is running."},
+		{"stepBack", nil, "Unsupported request 'stepBack'."},
+	}
+	for _, test := range tests {
+		response := client.request(test.command, test.arguments)
+		if response["success"] != false || !strings.Contains(response["message"].(string), test.message) {
+			t.Errorf("%s: expected failure %q, got %v", test.command, test.message, response)
+		}
+	}
+}
+
+func TestDapRuntimeError(t *testing.T) {
+	path := writeScript(t, "print 1;\nprint 1 / nil;\n")
+	client := startDap(t)
+	client.succeed("initialize", nil)
+	client.succeed("launch", map[string]interface{}{"program": path, "stopOnEntry": true})
+	client.succeed("configurationDone", nil)
+	if reason := client.event("stopped")["body"].(map[string]interface{})["reason"]; reason != "entry" {
+		t.Fatalf("expected to stop on entry, got %v", reason)
+	}
+	client.succeed("continue", nil)
+	if code := client.event("exited")["body"].(map[string]interface{})["exitCode"]; code != 70.0 {
+		t.Errorf("expected exit code 70, got %v", code)
+	}
+	if output := client.output(); !strings.Contains(output, "1\n") || !strings.Contains(output, "Right operand must be a number") {
+		t.Errorf("unexpected output %q", output)
+	}
+	client.succeed("disconnect", nil)
+}
+
+func jsonString(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type StepMode string
+
+const (
+	STEP_CONTINUE StepMode = "CONTINUE"
+	STEP_IN       StepMode = "IN"
+	STEP_OVER     StepMode = "OVER"
+	STEP_OUT      StepMode = "OUT"
+)
+
+// DebugFrontend is what the user drives the debugger through.
+type DebugFrontend interface {
+	// stopped runs on the interpreter goroutine whenever execution pauses
+	// and returns once the user resumes it.
+	stopped(debugger *Debugger, reason string) error
+}
+
+type DebugFrame struct {
+	Name        string
+	Line        int
+	environment *Environment
+}
+
+// DebugTerminated aborts a script when the user ends the debugging session.
+type DebugTerminated struct{}
+
+func (e DebugTerminated) Error() string {
+	return "Debugging session terminated."
+}
+
+// Debugger is a Tracer that pauses the interpreter on breakpoints and
+// steps. Everything but the breakpoint and pause requests must be used from
+// the interpreter goroutine while it is stopped.
+type Debugger struct {
+	interpreter *Interpreter
+	frontend    DebugFrontend
+	frames      []DebugFrame
+	mode        StepMode
+	stepDepth   int
+	entry       bool
+
+	mutex          sync.Mutex
+	breakpoints    map[int]bool
+	pauseRequested bool
+	terminated     bool
+}
+
+func NewDebugger(interpreter *Interpreter, frontend DebugFrontend, stopOnEntry bool) *Debugger {
+	debugger := &Debugger{
+		interpreter: interpreter,
+		frontend:    frontend,
+		frames:      []DebugFrame{{Name: "<script>"}},
+		mode:        STEP_CONTINUE,
+		entry:       stopOnEntry,
+		breakpoints: make(map[int]bool),
+	}
+	if stopOnEntry {
+		debugger.mode = STEP_IN
+	}
+	interpreter.tracers = append(interpreter.tracers, debugger)
+	return debugger
+}
+
+func (d *Debugger) statement(stmt Stmt) error {
+	if _, ok := stmt.(StmtBlock); ok {
+		return nil
+	}
+	line := stmtLine(stmt)
+	if line == 0 {
+		return nil
+	}
+	frame := &d.frames[len(d.frames)-1]
+	moved := frame.Line != line
+	frame.Line = line
+	frame.environment = d.interpreter.environment
+
+	reason := ""
+	d.mutex.Lock()
+	if d.terminated {
+		d.mutex.Unlock()
+		return DebugTerminated{}
+	}
+	if d.pauseRequested {
+		reason = "pause"
+		d.pauseRequested = false
+	} else if d.breakpoints[line] && moved {
+		reason = "breakpoint"
+	}
+	d.mutex.Unlock()
+
+	depth := len(d.frames)
+	if reason == "" {
+		switch d.mode {
+		case STEP_IN:
+			if moved || depth != d.stepDepth {
+				reason = "step"
+			}
+		case STEP_OVER:
+			if depth < d.stepDepth || (depth == d.stepDepth && moved) {
+				reason = "step"
+			}
+		case STEP_OUT:
+			if depth < d.stepDepth {
+				reason = "step"
+			}
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+	if d.entry {
+		reason = "entry"
+		d.entry = false
+	}
+	d.mode = STEP_CONTINUE
+	d.interpreter.flush()
+	if err := d.frontend.stopped(d, reason); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.terminated {
+		return DebugTerminated{}
+	}
+	return nil
+}
+
+func (d *Debugger) call(callee GloxCallable, expr ExprCall) error {
+	d.frames = append(d.frames, DebugFrame{Name: calleeName(callee, expr)})
+	return nil
+}
+
+func (d *Debugger) ret(callee GloxCallable, expr ExprCall) {
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// resume continues execution in the given mode once the frontend returns.
+func (d *Debugger) resume(mode StepMode) {
+	d.mode = mode
+	d.stepDepth = len(d.frames)
+}
+
+func (d *Debugger) setBreakpoints(lines []int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.breakpoints = make(map[int]bool)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+func (d *Debugger) toggleBreakpoint(line int, enabled bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if enabled {
+		d.breakpoints[line] = true
+	} else {
+		delete(d.breakpoints, line)
+	}
+}
+
+func (d *Debugger) breakpointLines() []int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var lines []int
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// requestPause stops the script at the next statement it runs.
+func (d *Debugger) requestPause() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.pauseRequested = true
+}
+
+// terminate makes the script fail with DebugTerminated at its next
+// statement.
+func (d *Debugger) terminate() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.terminated = true
+}
+
+// environments returns the chain of environments visible from a frame,
+// innermost first and ending with the globals. Frames are numbered from
+// the outermost script frame.
+func (d *Debugger) environments(frame int) []*Environment {
+	environment := d.frames[frame].environment
+	if environment == nil {
+		environment = d.interpreter.environment
+	}
+	var chain []*Environment
+	for ; environment != nil; environment = environment.enclosing {
+		chain = append(chain, environment)
+	}
+	return chain
+}
+
+func (d *Debugger) this(frame int) (interface{}, bool) {
+	for _, environment := range d.environments(frame) {
+		if value, ok := environment.lookup("this"); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// evaluate runs an expression as if it were written at the paused line of
+// a frame. The frame's environment chain is turned back into resolver
+// scopes so that names resolve exactly as they would in the script.
+func (d *Debugger) evaluate(source string, frame int) (interface{}, error) {
+	scanner := NewScanner(source)
+	tokens := scanner.scanTokens()
+	if len(scanner.errors) > 0 {
+		return nil, scanner.errors[0]
+	}
+	parser := NewParser(tokens)
+	expr, err := parser.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.isAtEnd() {
+		return nil, parser.error(parser.peek(), "Expect end of expression.")
+	}
+
+	interpreter := d.interpreter
+	chain := d.environments(frame)
+	locals := make(map[Expr]int, len(interpreter.locals))
+	for key, depth := range interpreter.locals {
+		locals[key] = depth
+	}
+	previousLocals, previousEnvironment, previousTracers := interpreter.locals, interpreter.environment, interpreter.tracers
+	interpreter.locals, interpreter.environment, interpreter.tracers = locals, chain[0], nil
+	defer func() {
+		interpreter.locals, interpreter.environment, interpreter.tracers = previousLocals, previousEnvironment, previousTracers
+	}()
+
+	resolver := NewResolver(interpreter)
+	for index := len(chain) - 2; index >= 0; index-- {
+		resolver.beginScope()
+		scope, _ := resolver.scopes.Peek()
+		for name := range chain[index].snapshot() {
+			scope[name] = true
+			if name == "this" && resolver.currentClass == NONE_CLASS {
+				resolver.currentClass = CLASS_RESOLVER
+			} else if name == "super" {
+				resolver.currentClass = SUBCLASS
+			}
+		}
+	}
+	if _, err := resolver.resolveExpr(expr); err != nil {
+		return nil, err
+	}
+	value, err := interpreter.evaluate(expr)
+	interpreter.flush()
+	return value, err
+}
+
+func sortedNames(values map[string]interface{}) []string {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DebugConsole is the command-line frontend of the debugger.
+type DebugConsole struct {
+	in     *bufio.Reader
+	out    io.Writer
+	source []string
+}
+
+func NewDebugConsole(source string, in io.Reader, out io.Writer) *DebugConsole {
+	return &DebugConsole{
+		in:     bufio.NewReader(in),
+		out:    out,
+		source: strings.Split(source, "\n"),
+	}
+}
+
+const debugConsoleHelp = `Commands:
+  b, break LINE      set a breakpoint
+  d, delete LINE     remove a breakpoint
+  c, continue        run until the next breakpoint
+  s, step            step into calls
+  n, next            step over calls
+  o, out             run until the current function returns
+  bt, backtrace      show the call stack
+  env                show the environment chain
+  this               show the current instance
+  p, print EXPR      evaluate an expression in the current frame
+  l, list            show the source around the current line
+  q, quit            end the session`
+
+func (c *DebugConsole) stopped(d *Debugger, reason string) error {
+	frame := d.frames[len(d.frames)-1]
+	fmt.Fprintf(c.out, "Stopped at line %d in %s (%s)\n", frame.Line, frame.Name, reason)
+	c.list(frame.Line, 0)
+	for {
+		fmt.Fprint(c.out, "(glox) ")
+		input, err := c.in.ReadString('\n')
+		if err != nil && input == "" {
+			return DebugTerminated{}
+		}
+		command, argument, _ := strings.Cut(strings.TrimSpace(input), " ")
+		argument = strings.TrimSpace(argument)
+		top := len(d.frames) - 1
+		switch command {
+		case "c", "continue":
+			d.resume(STEP_CONTINUE)
+			return nil
+		case "s", "step":
+			d.resume(STEP_IN)
+			return nil
+		case "n", "next":
+			d.resume(STEP_OVER)
+			return nil
+		case "o", "out":
+			d.resume(STEP_OUT)
+			return nil
+		case "b", "break", "d", "delete":
+			line, err := strconv.Atoi(argument)
+			if err != nil {
+				fmt.Fprintf(c.out, "Expect a line number, got '%s'.\n", argument)
+				continue
+			}
+			d.toggleBreakpoint(line, command == "b" || command == "break")
+			fmt.Fprintf(c.out, "Breakpoints: %v\n", d.breakpointLines())
+		case "bt", "backtrace":
+			for index := top; index >= 0; index-- {
+				fmt.Fprintf(c.out, "#%d %s at line %d\n", top-index, d.frames[index].Name, d.frames[index].Line)
+			}
+		case "env":
+			chain := d.environments(top)
+			for depth, environment := range chain {
+				label := fmt.Sprintf("[%d]", depth)
+				if depth == len(chain)-1 {
+					label = "[globals]"
+				}
+				fmt.Fprintln(c.out, label)
+				values := environment.snapshot()
+				for _, name := range sortedNames(values) {
+					fmt.Fprintf(c.out, "  %s = %v\n", name, values[name])
+				}
+			}
+		case "this":
+			if value, ok := d.this(top); ok {
+				c.printInstance(value)
+			} else {
+				fmt.Fprintln(c.out, "No 'this' in the current frame.")
+			}
+		case "p", "print":
+			value, err := d.evaluate(argument, top)
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+			} else {
+				fmt.Fprintln(c.out, value)
+			}
+		case "l", "list":
+			c.list(frame.Line, 5)
+		case "q", "quit":
+			d.terminate()
+			return DebugTerminated{}
+		default:
+			fmt.Fprintln(c.out, debugConsoleHelp)
+		}
+	}
+}
+
+func (c *DebugConsole) printInstance(value interface{}) {
+	instance, ok := value.(*GloxInstance)
+	if !ok {
+		fmt.Fprintln(c.out, value)
+		return
+	}
+	fmt.Fprintln(c.out, instance)
+	fields := instance.fields()
+	for _, name := range sortedNames(fields) {
+		fmt.Fprintf(c.out, "  %s = %v\n", name, fields[name])
+	}
+}
+
+func (c *DebugConsole) list(line int, context int) {
+	for number := line - context; number <= line+context; number++ {
+		if number < 1 || number > len(c.source) {
+			continue
+		}
+		marker := " "
+		if number == line {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s %4d | %s\n", marker, number, c.source[number-1])
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const debugTestSource = `class Point {
+  init(x) { this.x = x; }
+  twice() { return this.x * 2; }
+}
+fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var p = Point(3);
+print add(1, 2);
+print p.twice();
+`
+
+// debugSource runs source under the console debugger, stopped on entry,
+// feeding it commands, and returns the transcript and the run's error.
+func debugSource(t *testing.T, source string, commands string) (string, error) {
+	t.Helper()
+	statements := parseSource(t, source)
+	var output bytes.Buffer
+	interpreter := NewInterpreter()
+	interpreter.setStreams(strings.NewReader(""), &output, &output)
+	resolver := NewResolver(interpreter)
+	if err := resolver.resolveStatements(statements); err != nil {
+		t.Fatal(err)
+	}
+	NewDebugger(interpreter, NewDebugConsole(source, strings.NewReader(commands), &output), true)
+	_, err := interpreter.interpret(statements)
+	interpreter.flush()
+	return output.String(), err
+}
+
+func TestDebugConsole(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands string
+		// expected in this order in the transcript
+		expected   []string
+		terminated bool
+	}{
+		{
+			name:     "breakpoint and backtrace",
+			commands: "b 6\nc\nbt\nc\n",
+			expected: []string{"Stopped at line 1 in <script> (entry)", "Breakpoints: [6]",
+				"Stopped at line 6 in add (breakpoint)", "#0 add at line 6\n#1 <script> at line 10", "3\n6\n"},
+		},
+		{
+			name:     "print and env",
+			commands: "b 7\nc\np sum\np a * 10\nenv\nc\n",
+			expected: []string{"Stopped at line 7 in add (breakpoint)", "(glox) 3\n", "(glox) 10\n",
+				"[0]\n  a = 1\n  b = 2\n  sum = 3\n[globals]", "  p = Point Instance"},
+		},
+		{
+			name:     "step over and out",
+			commands: "b 6\nc\nn\no\nc\n",
+			expected: []string{"Stopped at line 6 in add (breakpoint)", "Stopped at line 7 in add (step)",
+				"Stopped at line 11 in <script> (step)", "6\n"},
+		},
+		{
+			name:     "step into a method and show this",
+			commands: "b 11\nc\ns\nthis\nc\n",
+			expected: []string{"Stopped at line 11 in <script> (breakpoint)", "Stopped at line 3 in twice (step)",
+				"Point Instance\n  x = 3\n"},
+		},
+		{
+			name:     "delete a breakpoint",
+			commands: "b 6\nd 6\nc\n",
+			expected: []string{"Breakpoints: [6]", "Breakpoints: []", "3\n6\n"},
+		},
+		{
+			name:     "bad commands",
+			commands: "b six\np nope\np 1 +\nthis\nwhat\nc\n",
+			expected: []string{"Expect a line number, got 'six'.", "Undefined variable 'nope'", "Expect expression.",
+				"No 'this' in the current frame.", "Commands:"},
+		},
+		{
+			name:       "quit",
+			commands:   "q\n",
+			expected:   []string{"(entry)"},
+			terminated: true,
+		},
+		{
+			name:       "end of input",
+			commands:   "",
+			expected:   []string{"(entry)"},
+			terminated: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := debugSource(t, debugTestSource, test.commands)
+			if _, ok := err.(DebugTerminated); ok != test.terminated {
+				t.Fatalf("expected terminated %v, got error %v", test.terminated, err)
+			}
+			if !test.terminated && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			rest := output
+			for _, expected := range test.expected {
+				index := strings.Index(rest, expected)
+				if index < 0 {
+					t.Fatalf("expected %q in transcript:\n%s", expected, output)
+				}
+				rest = rest[index+len(expected):]
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+// Destructuring is the left side of a destructuring declaration or
+// assignment. [a, b] takes the elements of a list in order, {x, y} the
+// properties of the same names of an instance.
+type Destructuring struct {
+	// the '[' or '{' that opens it
+	Bracket Token
+	Targets []ExprVariable
+}
+
+func (d Destructuring) isList() bool {
+	return d.Bracket.TokenType == LEFT_BRACKET
+}
+
+// destructure splits value into one value per target, failing when value
+// doesn't have the shape the target asks for.
+func (i *Interpreter) destructure(target Destructuring, value interface{}) ([]interface{}, error) {
+	if target.isList() {
+		list, ok := value.(*GloxList)
+		if !ok {
+			return nil, &RuntimeError{token: target.Bracket, message: fmt.Sprintf("Can't destructure %s as a list.", typeName(value))}
+		}
+		elements := list.values()
+		if len(elements) != len(target.Targets) {
+			return nil, &RuntimeError{token: target.Bracket, message: fmt.Sprintf("Can't destructure a list of %d elements into %d names.", len(elements), len(target.Targets))}
+		}
+		return elements, nil
+	}
+	switch value.(type) {
+	case GloxInstance, *GloxInstance, *GoObject, *GloxList:
+	default:
+		return nil, &RuntimeError{token: target.Bracket, message: fmt.Sprintf("Can't destructure the properties of %s.", typeName(value))}
+	}
+	values := make([]interface{}, len(target.Targets))
+	for index, variable := range target.Targets {
+		property, err := i.getProperty(value, variable.Name)
+		if err != nil {
+			return nil, err
+		}
+		values[index] = property
+	}
+	return values, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestDestructuring(t *testing.T) {
+	const point = "class P { init(x, y) { this.x = x; this.y = y; } }\n"
+	runScriptTests(t, []scriptTest{
+		{name: "list", source: "var [a, b] = [1, 2]; print a + b;", output: "3\n"},
+		{name: "returned list", source: "fun pair() { return [5, 6]; } var [c, d] = pair(); print c; print d;", output: "5\n6\n"},
+		{name: "properties", source: point + "var {x, y} = P(3, 4); print x * y;", output: "12\n"},
+		{name: "swap", source: "var a = 1; var b = 2; [a, b] = [b, a]; print \"${a} ${b}\";", output: "2 1\n"},
+		{name: "assignment is an expression", source: "var a; var b; print [a, b] = [1, 2];", output: "[1, 2]\n"},
+		{name: "locals", source: "fun f() { var [a, b] = [1, 2]; return b; } print f();", output: "2\n"},
+		{name: "properties of a list", source: "var {length} = [1, 2]; print length;", output: "2\n"},
+		{name: "in a block", source: "{ var [a, b] = [\"a\", \"b\"]; print a + b; }", output: "ab\n"},
+
+		{name: "too few elements", source: "var [a, b] = [1];", err: "Error at '[': Can't destructure a list of 1 elements into 2 names."},
+		{name: "too many elements", source: "var [a, b] = [1, 2, 3];", err: "Error at '[': Can't destructure a list of 3 elements into 2 names."},
+		{name: "assignment shape", source: "var a; var b; [a, b] = [1];", err: "Error at '[': Can't destructure a list of 1 elements into 2 names."},
+		{name: "not a list", source: "var v = 1; var [a] = v;", err: "Error at '[': Can't destructure number as a list."},
+		{name: "string as a list", source: "var v = \"ab\"; var [a, b] = v;", err: "Error at '[': Can't destructure string as a list."},
+		{name: "assigning a non-list", source: "var a; var b; var v = 1; [a, b] = v;", err: "Error at '[': Can't destructure number as a list."},
+		{name: "properties of nil", source: "var v = nil; var {a} = v;", err: "Error at '{': Can't destructure the properties of nil."},
+		{name: "missing property", source: "class E {} var {x} = E();", err: "Error at 'x': Undefined propety 'x'"},
+		{name: "properties of a number", source: "var v = 1.5; var {x} = v;", err: "Error at '{': Can't destructure the properties of number."},
+		{name: "statically not a list", source: "var [a] = 1;", err: "Type error at '[': Destructured value must be List but is Number."},
+	})
+}
+
+func TestDestructuringSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"var [] = [];", "Error at ']': Expect variable name."},
+		{"var [a, 1] = [1, 2];", "Error at '1': Expect variable name."},
+		{"var a; [a, 1] = [1, 2];", "Error at '=': Invalid assignment target."},
+		{"var {x: z} = 1;", "Error at ':': Expect '}' after names."},
+		{"var [a, a] = [1, 2];", "Error at 'a': Name 'a' is destructured into twice."},
+	}
+	for _, test := range tests {
+		if errors := parseErrors(test.source); len(errors) == 0 || errors[0] != test.err {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, errors)
+		}
+	}
+}
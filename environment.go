@@ -1,25 +1,71 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
+// Environment holds the variables of a scope. Spawned calls share the
+// globals and any closures with the goroutine that spawned them, so every
+// access goes through the lock.
 type Environment struct {
 	values    map[string]interface{}
 	enclosing *Environment
+	// names defined with defineConst, made on first use
+	constants map[string]bool
+	lock      *sync.RWMutex
 }
 
 func NewEnvironment(enclosing *Environment) Environment {
 	return Environment{
 		enclosing: enclosing,
 		values:    make(map[string]interface{}),
+		lock:      &sync.RWMutex{},
 	}
 }
 
 func (e *Environment) define(name string, value interface{}) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
 	e.values[name] = value
+	delete(e.constants, name)
+}
+
+// defineConst defines a name that assign refuses to change. The resolver
+// already rejects assignments to the constants it can see; this catches
+// globals assigned before their declaration was resolved.
+func (e *Environment) defineConst(name string, value interface{}) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.values[name] = value
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
+}
+
+// lookup reads a name defined in this scope only.
+func (e *Environment) lookup(name string) (interface{}, bool) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	value, ok := e.values[name]
+	return value, ok
+}
+
+// snapshot copies the variables of this scope, for listing them while
+// other goroutines may change them.
+func (e *Environment) snapshot() map[string]interface{} {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	values := make(map[string]interface{}, len(e.values))
+	for name, value := range e.values {
+		values[name] = value
+	}
+	return values
 }
 
 func (env *Environment) get(token Token) (interface{}, error) {
-	if value, ok := env.values[token.Lexeme]; ok {
+	if value, ok := env.lookup(token.Lexeme); ok {
 		return value, nil
 	} else if env.enclosing != nil {
 		return env.enclosing.get(token)
@@ -32,10 +78,20 @@ func (env *Environment) get(token Token) (interface{}, error) {
 }
 
 func (env *Environment) assign(token Token, value interface{}) error {
+	env.lock.Lock()
 	if _, ok := env.values[token.Lexeme]; ok {
+		defer env.lock.Unlock()
+		if env.constants[token.Lexeme] {
+			return &RuntimeError{
+				token:   token,
+				message: fmt.Sprintf("Can't assign to constant '%v'", token.Lexeme),
+			}
+		}
 		env.values[token.Lexeme] = value
 		return nil
-	} else if env.enclosing != nil {
+	}
+	env.lock.Unlock()
+	if env.enclosing != nil {
 		return env.enclosing.assign(token, value)
 	} else {
 		return &RuntimeError{
@@ -46,11 +102,15 @@ func (env *Environment) assign(token Token, value interface{}) error {
 }
 
 func (env *Environment) getAt(distance int, name string) interface{} {
-  return env.ancestor(distance).values[name]
+  value, _ := env.ancestor(distance).lookup(name)
+  return value
 }
 
 func (env *Environment) assignAt(distance int, name Token, value interface{}) {
-  env.ancestor(distance).values[name.Lexeme] = value
+  ancestor := env.ancestor(distance)
+  ancestor.lock.Lock()
+  defer ancestor.lock.Unlock()
+  ancestor.values[name.Lexeme] = value
 }
 
 func (env *Environment) ancestor(distance int) *Environment {
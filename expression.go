@@ -17,12 +17,22 @@ type ExprVisitor interface {
   visitSetExpr(expr ExprSet) (interface{}, error)
   visitThisExpr(expr ExprThis) (interface{}, error)
   visitSuperExpr(expr ExprSuper) (interface{}, error)
+  visitInterpolationExpr(expr ExprInterpolation) (interface{}, error)
+  visitUpdateExpr(expr ExprUpdate) (interface{}, error)
+  visitConditionalExpr(expr ExprConditional) (interface{}, error)
+  visitListExpr(expr ExprList) (interface{}, error)
+  visitDestructureExpr(expr ExprDestructure) (interface{}, error)
+  visitSpawnExpr(expr ExprSpawn) (interface{}, error)
+  visitIndexExpr(expr ExprIndex) (interface{}, error)
+  visitIndexSetExpr(expr ExprIndexSet) (interface{}, error)
 }
 
 type ExprCall struct {
 	Callee    *Expr
 	Paren     Token
 	Arguments []*Expr
+	// names of the trailing named arguments, as in f(1, b: 2)
+	Names []Token
 }
 
 type ExprBinary struct {
@@ -62,6 +72,8 @@ type ExprUnary struct {
 type ExprGet struct {
   Object *Expr
   Name Token
+  // set for obj?.name, which is nil when obj is
+  Optional bool
 }
 
 type ExprSet struct {
@@ -79,6 +91,67 @@ type ExprSuper struct {
   Method Token
 }
 
+type ExprConditional struct {
+  Condition *Expr
+  Question  Token
+  Then      *Expr
+  Else      *Expr
+}
+
+// ExprUpdate is a compound assignment such as a += b, or an increment or
+// decrement. Target is the ExprVariable, ExprGet or ExprIndex being updated,
+// Operator the binary operator applied to it and Value its right operand, 1
+// for ++ and --. A postfix update evaluates to the value before it.
+type ExprUpdate struct {
+  Target   Expr
+  Operator Token
+  Value    *Expr
+  Postfix  bool
+}
+
+// ExprInterpolation is a string with ${} expressions in it. Parts alternate
+// between the literal text and the expressions, starting and ending with
+// text.
+type ExprInterpolation struct {
+  Start Token
+  Parts []*Expr
+}
+
+// ExprList is a list literal such as [1, 2].
+type ExprList struct {
+  Bracket  Token
+  Elements []*Expr
+}
+
+// ExprIndex reads an element of a list, as in list[index].
+type ExprIndex struct {
+  Object  *Expr
+  Bracket Token
+  Index   *Expr
+}
+
+// ExprIndexSet assigns to an element of a list, as in list[index] = value.
+type ExprIndexSet struct {
+  Object  *Expr
+  Bracket Token
+  Index   *Expr
+  Value   *Expr
+}
+
+// ExprSpawn runs Call on a goroutine of its own and evaluates to a task
+// that can wait for it.
+type ExprSpawn struct {
+  Keyword Token
+  Call    ExprCall
+}
+
+// ExprDestructure assigns the parts of Value to several variables at once,
+// as in [a, b] = [b, a].
+type ExprDestructure struct {
+  Target Destructuring
+  Value  *Expr
+}
+
 func (e ExprBinary) accept(v ExprVisitor) (interface{}, error) {
   value, err := v.visitBinaryExpr(e)
   return value, err
@@ -130,3 +203,128 @@ func (e ExprThis) accept(v ExprVisitor) (interface{}, error) {
 func (e ExprSuper) accept(v ExprVisitor) (interface{}, error) {
   return v.visitSuperExpr(e)
 }
+
+func (e ExprConditional) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitConditionalExpr(e)
+}
+
+func (e ExprUpdate) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitUpdateExpr(e)
+}
+
+func (e ExprInterpolation) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitInterpolationExpr(e)
+}
+
+func (e ExprList) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitListExpr(e)
+}
+
+func (e ExprDestructure) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitDestructureExpr(e)
+}
+
+func (e ExprSpawn) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitSpawnExpr(e)
+}
+
+func (e ExprIndex) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitIndexExpr(e)
+}
+
+func (e ExprIndexSet) accept(v ExprVisitor) (interface{}, error) {
+  return v.visitIndexSetExpr(e)
+}
+
+// optionalChain reports whether a '?.' in expr's chain of property
+// accesses, calls and subscripts may skip the rest of it.
+func optionalChain(expr Expr) bool {
+	for {
+		switch link := expr.(type) {
+		case ExprGet:
+			if link.Optional {
+				return true
+			}
+			expr = *link.Object
+		case ExprCall:
+			expr = *link.Callee
+		case ExprIndex:
+			expr = *link.Object
+		default:
+			return false
+		}
+	}
+}
+
+// exprLine returns the line of the leftmost token of an expression, or 0
+// for literals, which keep no token.
+func exprLine(expr Expr) int {
+	switch e := expr.(type) {
+	case ExprBinary:
+		if line := exprLine(e.Left); line > 0 {
+			return line
+		}
+		return e.Operator.Line
+	case ExprGrouping:
+		return exprLine(*e.Expression)
+	case ExprUnary:
+		return e.Operator.Line
+	case ExprVariable:
+		return e.Name.Line
+	case ExprLogical:
+		if line := exprLine(*e.Left); line > 0 {
+			return line
+		}
+		return e.Operator.Line
+	case ExprAssign:
+		return e.Name.Line
+	case ExprCall:
+		if line := exprLine(*e.Callee); line > 0 {
+			return line
+		}
+		return e.Paren.Line
+	case ExprGet:
+		if line := exprLine(*e.Object); line > 0 {
+			return line
+		}
+		return e.Name.Line
+	case ExprSet:
+		if line := exprLine(*e.Object); line > 0 {
+			return line
+		}
+		return e.Name.Line
+	case ExprIndex:
+		if line := exprLine(*e.Object); line > 0 {
+			return line
+		}
+		return e.Bracket.Line
+	case ExprIndexSet:
+		if line := exprLine(*e.Object); line > 0 {
+			return line
+		}
+		return e.Bracket.Line
+	case ExprThis:
+		return e.Keyword.Line
+	case ExprSuper:
+		return e.Keyword.Line
+	case ExprInterpolation:
+		return e.Start.Line
+	case ExprList:
+		return e.Bracket.Line
+	case ExprDestructure:
+		return e.Target.Bracket.Line
+	case ExprSpawn:
+		return e.Keyword.Line
+	case ExprConditional:
+		if line := exprLine(*e.Condition); line > 0 {
+			return line
+		}
+		return e.Question.Line
+	case ExprUpdate:
+		if line := exprLine(e.Target); line > 0 {
+			return line
+		}
+		return e.Operator.Line
+	}
+	return 0
+}
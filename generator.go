@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// GloxGenerator is what calling a function that yields returns. Its body
+// runs on a goroutine and an Interpreter of its own, forked from the first
+// caller of next(), and hands control back and forth with whoever calls
+// next(), so only one of them runs at a time. Callers on different
+// goroutines take turns. For each step the body borrows the caller's
+// tracers and counts against the caller's limits.
+//
+// A generator that is never run to its end keeps its goroutine parked
+// until the process exits.
+type GloxGenerator struct {
+	function    GloxFunction
+	environment *Environment
+	resume      chan struct{}
+	steps       chan generatorStep
+	// holds a value while a caller runs a step
+	turn chan struct{}
+	// guards the fields below, as spawned calls may share the generator
+	lock sync.Mutex
+	// what the body runs on, once started
+	body *Interpreter
+	// the interpreter waiting on the current step, so that a next()
+	// reached from the body itself, directly or through other generators,
+	// fails instead of waiting for itself
+	caller *Interpreter
+	done   bool
+}
+
+// generatorStep is what the body hands back: a yielded value, or the end of
+// the body along with the error it failed with.
+type generatorStep struct {
+	value interface{}
+	done  bool
+	err   error
+}
+
+// newGenerator prepares a generator for a call whose parameters are
+// already bound in environment. Nothing runs until the first next().
+func newGenerator(function GloxFunction, environment *Environment) *GloxGenerator {
+	return &GloxGenerator{
+		function:    function,
+		environment: environment,
+		resume:      make(chan struct{}),
+		steps:       make(chan generatorStep),
+		turn:        make(chan struct{}, 1),
+	}
+}
+
+func (g *GloxGenerator) String() string {
+	return "<generator " + g.function.Declaration.Name.Lexeme + ">"
+}
+
+// Get exposes next(), which returns the next value yielded or nil once the
+// body has finished, and done, which tells the two apart.
+func (g *GloxGenerator) Get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "next":
+		return &nativeMethod{name: name, signature: fixedSignature("next", 0), fn: func(interpreter *Interpreter, _ []interface{}) (interface{}, error) {
+			step, err := g.next(interpreter, name)
+			if err != nil {
+				return nil, err
+			}
+			return step.value, nil
+		}}, nil
+	case "done":
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		return g.done, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+// next runs the body up to its next yield, or to its end.
+func (g *GloxGenerator) next(interpreter *Interpreter, token Token) (generatorStep, error) {
+	if g.reentered(interpreter) {
+		return generatorStep{}, &RuntimeError{token: token, message: "Generator is already running."}
+	}
+	select {
+	case g.turn <- struct{}{}:
+	case <-interpreter.done():
+		return generatorStep{}, interpreter.checkCancelled(token)
+	}
+	defer func() { <-g.turn }()
+
+	g.lock.Lock()
+	if g.done {
+		g.lock.Unlock()
+		return generatorStep{done: true}, nil
+	}
+	g.caller = interpreter
+	start := g.body == nil
+	if start {
+		g.body = interpreter.fork()
+		g.body.generator = g
+	}
+	body := g.body
+	g.lock.Unlock()
+
+	// the body is parked, so its interpreter can be handed the caller's
+	// state until it hands back the next step
+	body.tracers = interpreter.tracers
+	body.steps, body.callDepth, body.allocated = interpreter.steps, interpreter.callDepth, interpreter.allocated
+	if start {
+		go g.run(body)
+	} else {
+		g.resume <- struct{}{}
+	}
+	step := <-g.steps
+	interpreter.steps, interpreter.allocated = body.steps, body.allocated
+	body.tracers = nil
+
+	g.lock.Lock()
+	g.caller = nil
+	g.done = step.done
+	g.lock.Unlock()
+	return step, step.err
+}
+
+// reentered tells whether interpreter is the body of g, or the body of a
+// generator resumed, through any others, from the body of g.
+func (g *GloxGenerator) reentered(interpreter *Interpreter) bool {
+	for interpreter != nil && interpreter.generator != nil {
+		if interpreter.generator == g {
+			return true
+		}
+		outer := interpreter.generator
+		outer.lock.Lock()
+		interpreter = outer.caller
+		outer.lock.Unlock()
+	}
+	return false
+}
+
+func (g *GloxGenerator) run(body *Interpreter) {
+	err := body.executeBlock(g.function.Declaration.Body, g.environment)
+	if _, ok := err.(Return); ok {
+		err = nil
+	}
+	g.steps <- generatorStep{done: true, err: err}
+}
+
+// yield hands value to the caller of next() and waits to be resumed.
+func (g *GloxGenerator) yield(value interface{}) {
+	g.steps <- generatorStep{value: value}
+	<-g.resume
+}
+
+// iterate returns a function giving the elements of a list, the values a
+// generator yields or those received from a channel, one at a time; ok is
+// false after the last.
+func (i *Interpreter) iterate(token Token, iterable interface{}) (func() (interface{}, bool, error), error) {
+	switch iterable := iterable.(type) {
+	case *GloxList:
+		index := 0
+		return func() (interface{}, bool, error) {
+			// the length is read on every step, so pushes during the loop
+			// are visited too
+			element, ok := iterable.at(index)
+			index++
+			return element, ok, nil
+		}, nil
+	case *GloxGenerator:
+		return func() (interface{}, bool, error) {
+			step, err := iterable.next(i, token)
+			return step.value, !step.done, err
+		}, nil
+	case *GloxChannel:
+		// receives until the channel is closed and drained
+		return func() (interface{}, bool, error) {
+			select {
+			case value, ok := <-iterable.channel:
+				return value, ok, nil
+			case <-i.done():
+				return nil, false, i.checkCancelled(token)
+			}
+		}, nil
+	}
+	return nil, &RuntimeError{token: token, message: fmt.Sprintf("Can only iterate over lists, generators and channels, but got %s.", typeName(iterable))}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	const count = "fun count(n) { var i = 0; while (i < n) { yield i; i = i + 1; } }\n"
+	runScriptTests(t, []scriptTest{
+		{name: "next and done", source: count + "var g = count(2); print g; print g.next(); print g.done; print g.next(); print g.done; print g.next(); print g.done; print g.next();",
+			output: "<generator count>\n0\nfalse\n1\nfalse\n<nil>\ntrue\n<nil>\n"},
+		{name: "for in", source: count + "for (var x in count(3)) print x;", output: "0\n1\n2\n"},
+		{name: "infinite", source: "fun fib() { var a = 0; var b = 1; while (true) { yield a; [a, b] = [b, a + b]; } }\nvar f = fib(); var i = 0; while (i < 6) { print f.next(); i = i + 1; }",
+			output: "0\n1\n1\n2\n3\n5\n"},
+		{name: "nested", source: "fun inner() { yield 1; yield 2; }\nfun outer() { for (var x in inner()) yield x * 10; }\nfor (var x in outer()) print x;",
+			output: "10\n20\n"},
+		{name: "independent", source: count + "var a = count(2); var b = count(2); print a.next(); print a.next(); print b.next();", output: "0\n1\n0\n"},
+		{name: "nothing yielded", source: "fun none() { if (false) yield 1; return; }\nvar n = none(); print n.next(); print n.done;", output: "<nil>\ntrue\n"},
+		{name: "closure", source: "var total = 0; fun g() { total = total + 1; yield total; }\nvar x = g(); print total; x.next(); print total;", output: "0\n1\n"},
+		{name: "method", source: "class C { init() { this.items = [1, 2]; } each() { for (var x in this.items) yield x; } }\nfor (var x in C().each()) print x;",
+			output: "1\n2\n"},
+
+		{name: "resumed from its own body", source: "var x; fun g() { yield x.next(); } x = g(); x.next();",
+			err: "Error at 'next': Generator is already running."},
+		{name: "resumed through another generator", source: "var a; fun g() { yield b.next(); } fun h() { yield a.next(); } a = g(); var b = h(); a.next();",
+			err: "Error at 'next': Generator is already running."},
+		{name: "error in the body", source: "fun g() { yield 1; print 1 / nil; } var x = g(); print x.next(); x.next();",
+			output: "1\n", err: "Error at '/': Right operand must be a number, but got nil."},
+		{name: "arguments to next", source: "fun g() { yield 1; } var x = g(); x.next(1);", err: "Error at ')': next() takes 0 arguments but got 1."},
+		{name: "unknown property", source: "fun g() { yield 1; } var x = g(); x.send(1);", err: "Error at 'send': Undefined propety 'send'"},
+		{name: "return value", source: "fun g() { yield 1; return 2; }", err: "Error at 'return': Can't return a value from a generator."},
+		{name: "top level", source: "yield 1;", err: "Error at 'yield': Can't yield from top-level code."},
+		{name: "initializer", source: "class C { init() { yield 1; } }", err: "Error at 'yield': Can't yield from an initializer."},
+	})
+}
+
+func TestGeneratorLimits(t *testing.T) {
+	_, err := runSource(t, "fun g() { while (true) yield 1; }\nvar x = g();\nwhile (true) x.next();", withLimits(Limits{MaxSteps: 500}))
+	var limitError *LimitError
+	if !errors.As(err, &limitError) || limitError.Kind != STEP_LIMIT {
+		t.Fatalf("expected the body's steps to count towards the limit, got %v", err)
+	}
+}
+
+// TestGeneratorFailure resumes a generator from Go after its body failed.
+func TestGeneratorFailure(t *testing.T) {
+	var interpreter *Interpreter
+	output, err := runSource(t, "fun g() { yield 1; print 1 / nil; }\nvar x = g();", func(i *Interpreter) { interpreter = i })
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := interpreter.lookupGlobal("x")
+	generator := value.(*GloxGenerator)
+	next, err := generator.Get(Token{TokenType: IDENTIFIER, Lexeme: "next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if value, err := interpreter.callFunction(ctx, next); err != nil || value != int64(1) {
+		t.Fatalf("expected 1, got %v, %v", value, err)
+	}
+	if _, err := interpreter.callFunction(ctx, next); err == nil || !strings.Contains(err.Error(), "Right operand must be a number") {
+		t.Fatalf("expected the body's error, got %v", err)
+	}
+	if done, _ := generator.Get(Token{TokenType: IDENTIFIER, Lexeme: "done"}); done != true {
+		t.Errorf("expected the generator to be done after failing, got %v", done)
+	}
+	if value, err := interpreter.callFunction(ctx, next); err != nil || value != nil {
+		t.Errorf("expected nil from a failed generator, got %v, %v", value, err)
+	}
+	if output != "" {
+		t.Errorf("unexpected output %q", output)
+	}
+}
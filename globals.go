@@ -1,11 +1,15 @@
 package main
 
-import "time"
+import (
+	"io"
+	"strings"
+	"time"
+)
 
 type Time struct{}
 
-func (t Time) Arity() int {
-  return 0
+func (t Time) Signature() Signature {
+  return fixedSignature("clock", 0)
 }
 
 func (t Time) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
@@ -15,3 +19,26 @@ func (t Time) Call(interpreter *Interpreter, arguments []interface{}) (interface
 func (t Time) String() string {
   return "<native fn>"
 }
+
+// ReadLine reads a line from the interpreter's stdin without its line
+// ending, or nil at the end of the input.
+type ReadLine struct{}
+
+func (r ReadLine) Signature() Signature {
+  return fixedSignature("readLine", 0)
+}
+
+func (r ReadLine) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+  interpreter.flush()
+  line, err := interpreter.stdin.ReadString('\n')
+  if err == io.EOF && line == "" {
+    return nil, nil
+  } else if err != nil && err != io.EOF {
+    return nil, err
+  }
+  return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (r ReadLine) String() string {
+  return "<native fn>"
+}
@@ -2,17 +2,32 @@ package main
 
 import (
 	"bufio"
+	"context"
 	//"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var hadError bool
 var hadRuntimeError bool
 
 type Glox struct {
+	// path of the script being run, if any
+	path string
+	// profile, when set, is where the folded call stacks of a profiled run
+	// are written
+	profile string
+	// coverage, when set, is where the LCOV report of the run is written
+	coverage string
+	limits   Limits
+	// timeout, when set, stops the script after that long
+	timeout time.Duration
+	// coercion is how operators treat operands of the wrong type
+	coercion Coercion
 }
 
 func (g Glox) runFile(path string) error {
@@ -27,20 +42,142 @@ func (g Glox) runFile(path string) error {
 		return err
 	}
 	var source = string(bytes)
+	g.path = path
 	g.run(source)
 	return nil
 }
 
+// lintFile prints the resolver's warnings and the checker's type errors for
+// a script and reports whether any problem was found.
+func (g Glox) lintFile(path string) (bool, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	scanner := NewScanner(string(bytes))
+	tokens := scanner.scanTokens()
+	for _, err := range scanner.errors {
+		fmt.Println(err)
+		hadError = true
+	}
+	parser := NewParser(tokens)
+	statements, errors := parser.parse()
+	if len(errors) > 0 {
+		for _, err := range errors {
+			fmt.Println(err)
+		}
+		return true, nil
+	}
+	resolver := NewResolver(NewInterpreter())
+	warnings, resolveErr := resolver.lint(statements)
+	for _, warning := range warnings {
+		reportWarning(warning.token, warning.message)
+	}
+	if resolveErr != nil {
+		fmt.Println(resolveErr)
+		return true, nil
+	}
+	checker := NewChecker(&resolver)
+	typeErrors := checker.check(statements)
+	for _, err := range typeErrors {
+		fmt.Println(err)
+	}
+	return hadError || len(typeErrors) > 0 || len(warnings) > 0, nil
+}
+
+// debugFile runs a script under the command-line debugger, stopped before
+// its first statement.
+func (g Glox) debugFile(path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	source := string(bytes)
+	scanner := NewScanner(source)
+	tokens := scanner.scanTokens()
+	if len(scanner.errors) > 0 {
+		return scanner.errors[0]
+	}
+	parser := NewParser(tokens)
+	statements, errors := parser.parse()
+	if len(errors) > 0 {
+		return errors[0]
+	}
+	interpreter := NewInterpreter()
+	interpreter.limits = g.limits
+	interpreter.coercion = g.coercion
+	resolver := NewResolver(interpreter)
+	if err := resolver.resolveStatements(statements); err != nil {
+		return err
+	}
+	checker := NewChecker(&resolver)
+	if typeErrors := checker.check(statements); len(typeErrors) > 0 {
+		return typeErrors[0]
+	}
+	NewDebugger(interpreter, NewDebugConsole(source, os.Stdin, os.Stdout), true)
+	_, err = interpreter.interpret(statements)
+	interpreter.flush()
+	if err != nil {
+		if _, ok := err.(DebugTerminated); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProfile prints the profiler's report to stderr and writes the folded
+// stacks next to it.
+func (g Glox) writeProfile(profiler *Profiler) {
+	profiler.finish()
+	profiler.writeReport(os.Stderr)
+	file, err := os.Create(g.profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer file.Close()
+	if err := profiler.writeFolded(file); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nFolded stacks written to %s\n", g.profile)
+}
+
+// writeCoverage prints the coverage summary to stderr, writes the annotated
+// listing next to the script and the LCOV report to the chosen path.
+func (g Glox) writeCoverage(coverage *Coverage) {
+	coverage.writeSummary(os.Stderr)
+	listing, err := os.Create(g.path + ".cov")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer listing.Close()
+	coverage.writeListing(listing)
+	lcov, err := os.Create(g.coverage)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer lcov.Close()
+	coverage.writeLcov(lcov)
+	fmt.Fprintf(os.Stderr, "Annotated source written to %s, LCOV report to %s\n", g.path+".cov", g.coverage)
+}
+
+func reportWarning(token Token, message string) {
+	fmt.Printf("[line %d:%d] Warning at '%s': %s\n", token.Line, token.Column, token.Lexeme, message)
+}
+
 func reportError(line int, message string) {
 	report(line, "", message)
 }
 
 func report(line int, where string, message string) {
-	fmt.Printf("[line %d] Error %s: %s\n", line, where, message)
+	fmt.Fprintf(os.Stderr, "[line %d] Error %s: %s\n", line, where, message)
 }
 
 func (g Glox) runtimeError(err RuntimeError) {
-	fmt.Printf("%s\n[line %d]\n", err.message, err.token.Line)
+	fmt.Fprintf(os.Stderr, "%s\n[line %d]\n", err.message, err.token.Line)
 	hadRuntimeError = true
 	os.Exit(70)
 }
@@ -67,6 +204,8 @@ func (g Glox) runPrompt() {
 		if line == "" {
 			break
 		}
+		hadError = false
+		hadRuntimeError = false
 		g.run(line, &environment)
 	}
 }
@@ -74,17 +213,21 @@ func (g Glox) runPrompt() {
 func (g Glox) run(source string, env ...*Environment) {
 	scanner := NewScanner(source)
 	tokens := scanner.scanTokens()
+	for _, err := range scanner.errors {
+		fmt.Fprintln(os.Stderr, err)
+		hadError = true
+	}
 	parser := NewParser(tokens)
 	statements, errors := parser.parse()
 	if hadError {
-		fmt.Println("Error parsing expression")
+		fmt.Fprintln(os.Stderr, "Error parsing expression")
 		return
 	}
 	if len(errors) > 0 {
 		for _, err := range errors {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 		}
-		//os.Exit(65)
+		hadError = true
 	} else {
 		//var environment *Environment;
 		//if len(env) > 0 {
@@ -93,6 +236,16 @@ func (g Glox) run(source string, env ...*Environment) {
 		//  environment = NewEnvironment(nil)
 		//}
 		interpreter := NewInterpreter()
+		interpreter.limits = g.limits
+		interpreter.coercion = g.coercion
+		var profiler *Profiler
+		if g.profile != "" {
+			profiler = NewProfiler(interpreter)
+		}
+		var coverage *Coverage
+		if g.coverage != "" {
+			coverage = NewCoverage(interpreter, statements, g.path, source)
+		}
 		//if len(statements) == 1 {
 		//	var stmt = statements[0]
 		//	if stmt, ok := stmt.(StmtExpression); ok {
@@ -107,7 +260,17 @@ func (g Glox) run(source string, env ...*Environment) {
 		resolver := NewResolver(interpreter)
 		errors := resolver.resolveStatements(statements)
 		if errors != nil {
-			fmt.Println(errors)
+			fmt.Fprintln(os.Stderr, errors)
+			hadError = true
+			return
+		}
+		checker := NewChecker(&resolver)
+		if typeErrors := checker.check(statements); len(typeErrors) > 0 {
+			for _, err := range typeErrors {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			hadError = true
+			return
 		}
 		//for _, stmt := range statements {
 		//	stmtJSON, err := json.MarshalIndent(stmt, "", "  ")
@@ -117,21 +280,140 @@ func (g Glox) run(source string, env ...*Environment) {
 		//	}
 	  //  fmt.Println(string(stmtJSON))
 		//}
-		value, err := interpreter.interpret(statements)
+		ctx := context.Background()
+		if g.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, g.timeout)
+			defer cancel()
+		}
+		_, err := interpreter.interpretContext(ctx, statements)
 		if err != nil {
-			fmt.Println(err)
+			interpreter.reportError(err)
+			hadRuntimeError = true
+		}
+		interpreter.waitForTasks()
+		if interpreter.taskFailed.Load() {
+			hadRuntimeError = true
+		}
+		if err := interpreter.flush(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if profiler != nil {
+			g.writeProfile(profiler)
+		}
+		if coverage != nil {
+			g.writeCoverage(coverage)
 		}
-		fmt.Println(value)
 	}
 }
 
+const usage = `Usage: glox [options] [script]
+       glox lint [script]
+       glox debug [script]
+       glox lsp
+       glox dap
+Options:
+  --profile[=FILE]   time the script and write folded stacks to FILE
+                     (default: the script path with a .folded suffix)
+  --coverage[=FILE]  record line and branch coverage, write an annotated
+                     listing to the script path with a .cov suffix and an
+                     LCOV report to FILE (default: suffix .lcov)
+  --max-steps=N      stop after N statements and expressions
+  --max-depth=N      stop when calls nest deeper than N (default: 10000)
+  --max-memory=N     stop after about N bytes of strings and instances
+  --timeout=DURATION stop the script after DURATION, such as 500ms or 2s
+                     (a limit or timeout of 0 means unlimited)
+  --lenient          convert numeric strings in arithmetic and let '+'
+                     join a string with any value`
+
 func main() {
-	argCount := len(os.Args)
-	g := Glox{}
-	if argCount > 2 {
-		fmt.Println("Usage: glox [script]")
+	g := Glox{limits: DefaultLimits(), coercion: STRICT_COERCION}
+	args := os.Args[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		option, value, hasValue := strings.Cut(args[0], "=")
+		switch option {
+		case "--profile":
+			g.profile = value
+			if !hasValue {
+				g.profile = "-"
+			}
+		case "--coverage":
+			g.coverage = value
+			if !hasValue {
+				g.coverage = "-"
+			}
+		case "--max-steps", "--max-depth", "--max-memory":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(64)
+			}
+			switch option {
+			case "--max-steps":
+				g.limits.MaxSteps = limit
+			case "--max-depth":
+				g.limits.MaxCallDepth = limit
+			case "--max-memory":
+				g.limits.MaxAllocation = limit
+			}
+		case "--timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < 0 {
+				fmt.Fprintln(os.Stderr, usage)
+				os.Exit(64)
+			}
+			g.timeout = timeout
+		case "--lenient":
+			g.coercion = LENIENT_COERCION
+		default:
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(64)
+		}
+		args = args[1:]
+	}
+	argCount := len(args) + 1
+	if argCount == 2 && args[0] == "lsp" {
+		os.Exit(NewLspServer(os.Stdin, os.Stdout).serve())
+	} else if argCount == 2 && args[0] == "dap" {
+		os.Exit(NewDapServer(os.Stdin, os.Stdout).serve())
+	} else if argCount == 3 && args[0] == "debug" {
+		if err := g.debugFile(args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(70)
+		}
+	} else if argCount == 3 && args[0] == "lint" {
+		found, err := g.lintFile(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(66)
+		}
+		if found {
+			os.Exit(1)
+		}
+	} else if argCount > 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(64)
 	} else if argCount == 2 {
-		g.runFile(os.Args[1])
+		if g.profile == "-" {
+			g.profile = args[0] + ".folded"
+		}
+		if g.coverage == "-" {
+			g.coverage = args[0] + ".lcov"
+		}
+		if err := g.runFile(args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(66)
+		}
+		if hadError {
+			os.Exit(65)
+		}
+		if hadRuntimeError {
+			os.Exit(70)
+		}
+	} else if g.profile != "" || g.coverage != "" {
+		// the reports are written next to the script
+		fmt.Fprintln(os.Stderr, "--profile and --coverage need a script to run.")
+		os.Exit(64)
 	} else {
 		g.runPrompt()
 	}
@@ -1,8 +1,18 @@
 package main
 
+// GloxCallable is anything scripts can call. Call gets the arguments in
+// parameter order, as Signature().bind puts them.
 type GloxCallable interface {
 	Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
-	Arity() int
+	Signature() Signature
+}
+
+// callableAt is implemented by natives whose errors point at the call.
+// The interpreter calls callAt with the call's closing parenthesis in
+// place of Call.
+type callableAt interface {
+	GloxCallable
+	callAt(interpreter *Interpreter, paren Token, arguments []interface{}) (interface{}, error)
 }
 
 type GloxFunction struct {
@@ -13,8 +23,11 @@ type GloxFunction struct {
 
 func (f GloxFunction) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
 	environment := NewEnvironment(f.Closure)
-	for i, paramName := range f.Declaration.Params {
-		environment.define(paramName.Lexeme, arguments[i])
+	if err := f.bindParams(interpreter, &environment, arguments); err != nil {
+		return nil, err
+	}
+	if f.Declaration.Generator {
+		return newGenerator(f, &environment), nil
 	}
 	err := interpreter.executeBlock(f.Declaration.Body, &environment)
 	if err == nil {
@@ -29,8 +42,48 @@ func (f GloxFunction) Call(interpreter *Interpreter, arguments []interface{}) (i
 	}
 }
 
-func (f GloxFunction) Arity() int {
-	return len(f.Declaration.Params)
+// bindParams defines the parameters in the function's environment. Left
+// out parameters take their default, evaluated there so that it can use the
+// parameters before it, and a rest parameter gets a list of the remaining
+// arguments.
+func (f GloxFunction) bindParams(interpreter *Interpreter, environment *Environment, arguments []interface{}) error {
+	params := f.Declaration.Params
+	for index, param := range params {
+		if f.Declaration.Rest && index == len(params)-1 {
+			var rest []interface{}
+			if index < len(arguments) {
+				rest = append(rest, arguments[index:]...)
+			}
+			environment.define(param.Lexeme, NewGloxList(rest))
+			break
+		}
+		var value interface{}
+		if index < len(arguments) {
+			value = arguments[index]
+		} else {
+			value = omitted{}
+		}
+		if _, ok := value.(omitted); ok {
+			if def := f.Declaration.Defaults[index]; def != nil {
+				previous := interpreter.environment
+				interpreter.environment = environment
+				var err error
+				value, err = interpreter.evaluate(*def)
+				interpreter.environment = previous
+				if err != nil {
+					return err
+				}
+			} else {
+				value = nil
+			}
+		}
+		environment.define(param.Lexeme, value)
+	}
+	return nil
+}
+
+func (f GloxFunction) Signature() Signature {
+	return functionSignature(f.Declaration)
 }
 
 func (f GloxFunction) String() string {
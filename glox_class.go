@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sync"
 )
 
 type GloxClass struct {
@@ -10,9 +11,12 @@ type GloxClass struct {
   Superclass *GloxClass
 }
 
+// GloxInstance is passed around by value as well as by pointer; the copies
+// share Fields and the lock that guards them against spawned calls.
 type GloxInstance struct {
 	Klass  *GloxClass
 	Fields map[string]interface{}
+	lock   *sync.RWMutex
 }
 
 func NewGloxClass(name string, methods map[string]GloxFunction, superclass *GloxClass) GloxClass {
@@ -27,6 +31,7 @@ func NewGloxInstance(klass GloxClass) GloxInstance {
 	return GloxInstance{
 		Klass:  &klass,
 		Fields: make(map[string]interface{}),
+		lock:   &sync.RWMutex{},
 	}
 }
 
@@ -49,16 +54,18 @@ func (i GloxInstance) String() string {
 	return fmt.Sprintf("%s Instance", i.Klass.Name)
 }
 
-func (f GloxClass) Arity() int {
-  if initializer, ok := f.Methods["init"]; ok {
-    return initializer.Arity()
+func (f GloxClass) Signature() Signature {
+  if initializer := f.FindMethod("init"); initializer != nil {
+    signature := initializer.Signature()
+    signature.Name = f.Name
+    return signature
   }
-	return 0
+	return fixedSignature(f.Name, 0)
 }
 
 func (f GloxClass) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
 	instance := NewGloxInstance(f)
-  if initializer, ok := f.Methods["init"]; ok {
+  if initializer := f.FindMethod("init"); initializer != nil {
     _, err := initializer.Bind(&instance).Call(interpreter, arguments)
     if err != nil {
       return nil, err
@@ -68,7 +75,7 @@ func (f GloxClass) Call(interpreter *Interpreter, arguments []interface{}) (inte
 }
 
 func (i *GloxInstance) Get(name Token) (interface{}, error) {
-	if value, ok := i.Fields[name.Lexeme]; ok {
+	if value, ok := i.field(name.Lexeme); ok {
 		return value, nil
 	} else {
     method := i.Klass.FindMethod(name.Lexeme)
@@ -83,5 +90,26 @@ func (i *GloxInstance) Get(name Token) (interface{}, error) {
 }
 
 func (i *GloxInstance) Set(name Token, value interface{}) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
 	i.Fields[name.Lexeme] = value
 }
+
+func (i *GloxInstance) field(name string) (interface{}, bool) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	value, ok := i.Fields[name]
+	return value, ok
+}
+
+// fields copies the fields, for listing them while other goroutines may
+// change them.
+func (i *GloxInstance) fields() map[string]interface{} {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	fields := make(map[string]interface{}, len(i.Fields))
+	for name, value := range i.Fields {
+		fields[name] = value
+	}
+	return fields
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// parseSource scans and parses source, failing the test on a syntax error.
+func parseSource(t *testing.T, source string) []*Stmt {
+	t.Helper()
+	scanner := NewScanner(source)
+	tokens := scanner.scanTokens()
+	if len(scanner.errors) > 0 {
+		t.Fatalf("scanning %q: %v", source, scanner.errors[0])
+	}
+	parser := NewParser(tokens)
+	statements, errors := parser.parse()
+	if len(errors) > 0 {
+		t.Fatalf("parsing %q: %v", source, errors[0])
+	}
+	return statements
+}
+
+// parseErrors scans and parses source and returns the messages of the
+// syntax errors it has.
+func parseErrors(source string) []string {
+	scanner := NewScanner(source)
+	parser := NewParser(scanner.scanTokens())
+	_, errors := parser.parse()
+	var messages []string
+	for _, err := range append(scanner.errors, errors...) {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// runSource runs source the way glox runs a script and returns what it
+// printed along with the first error of the resolver, the checker or the
+// run. setup, if given, gets the interpreter before anything is resolved.
+func runSource(t *testing.T, source string, setup ...func(*Interpreter)) (string, error) {
+	t.Helper()
+	statements := parseSource(t, source)
+	var stdout, stderr bytes.Buffer
+	interpreter := NewInterpreter()
+	interpreter.setStreams(strings.NewReader(""), &stdout, &stderr)
+	for _, configure := range setup {
+		configure(interpreter)
+	}
+	resolver := NewResolver(interpreter)
+	if err := resolver.resolveStatements(statements); err != nil {
+		return "", err
+	}
+	checker := NewChecker(&resolver)
+	if typeErrors := checker.check(statements); len(typeErrors) > 0 {
+		return "", typeErrors[0]
+	}
+	_, err := interpreter.interpret(statements)
+	interpreter.flush()
+	return stdout.String(), err
+}
+
+// scriptTest is a script together with what it should print, or the
+// message of the error it should fail with.
+type scriptTest struct {
+	name   string
+	source string
+	output string
+	err    string
+}
+
+// runScriptTests runs each test as a subtest.
+func runScriptTests(t *testing.T, tests []scriptTest, setup ...func(*Interpreter)) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := runSource(t, test.source, setup...)
+			if test.err != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got none; output %q", test.err, output)
+				}
+				if !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %q", test.err, err)
+				}
+				if test.output != "" && output != test.output {
+					t.Fatalf("expected output %q before the error, got %q", test.output, output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output != test.output {
+				t.Fatalf("expected output %q, got %q", test.output, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// GoFunction exposes a Go function or method value to scripts. Arguments
+// and results are converted with toGo and fromGo; a non-nil error result
+// becomes a runtime error.
+type GoFunction struct {
+	name string
+	fn   reflect.Value
+}
+
+// GoObject exposes a Go value, usually a pointer to a struct, as an
+// instance whose properties are its exported fields and methods.
+type GoObject struct {
+	value reflect.Value
+}
+
+// defineGo makes a Go function or value available to scripts as a global.
+func (i *Interpreter) defineGo(name string, value interface{}) error {
+	converted, err := fromGo(name, reflect.ValueOf(value))
+	if err != nil {
+		return err
+	}
+	i.globals.define(name, converted)
+	return nil
+}
+
+func newGoFunction(name string, fn reflect.Value) (GoFunction, error) {
+	t := fn.Type()
+	results := t.NumOut()
+	if results > 0 && t.Out(results-1) == errorType {
+		results--
+	}
+	if results > 1 {
+		return GoFunction{}, fmt.Errorf("Go function '%s' returns more than one value and an error.", name)
+	}
+	return GoFunction{name: name, fn: fn}, nil
+}
+
+func (f GoFunction) Signature() Signature {
+	t := f.fn.Type()
+	if t.IsVariadic() {
+		return Signature{Name: f.name, MinArity: t.NumIn() - 1, MaxArity: -1}
+	}
+	return fixedSignature(f.name, t.NumIn())
+}
+
+func (f GoFunction) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	t := f.fn.Type()
+	in := make([]reflect.Value, len(arguments))
+	for index, argument := range arguments {
+		parameter := t.In(min(index, t.NumIn()-1))
+		if t.IsVariadic() && index >= t.NumIn()-1 {
+			// the remaining arguments fill the variadic slice
+			parameter = parameter.Elem()
+		}
+		value, err := toGo(argument, parameter)
+		if err != nil {
+			return nil, f.error(fmt.Sprintf("Argument %d: %v", index+1, err))
+		}
+		in[index] = value
+	}
+	out := f.fn.Call(in)
+	if len(out) > 0 && t.Out(len(out)-1) == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, f.error(err.Error())
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	result, err := fromGo(f.name, out[0])
+	if err != nil {
+		return nil, f.error(err.Error())
+	}
+	return result, nil
+}
+
+func (f GoFunction) error(message string) error {
+	return &RuntimeError{token: Token{TokenType: IDENTIFIER, Lexeme: f.name}, message: message}
+}
+
+func (f GoFunction) String() string {
+	return "<native fn " + f.name + ">"
+}
+
+func (o *GoObject) String() string {
+	value := o.value
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
+
+// field finds an exported struct field, accepting the name as written in Go
+// or with its first letter lowercased.
+func (o *GoObject) field(name string) (reflect.Value, bool) {
+	value := o.value
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	for _, candidate := range []string{name, exportedName(name)} {
+		if field, ok := value.Type().FieldByName(candidate); ok && field.IsExported() {
+			return value.FieldByIndex(field.Index), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	first := []rune(name)[0]
+	return string(unicode.ToUpper(first)) + name[len(string(first)):]
+}
+
+func (o *GoObject) Get(name Token) (interface{}, error) {
+	if field, ok := o.field(name.Lexeme); ok {
+		value, err := fromGo(name.Lexeme, field)
+		if err != nil {
+			return nil, &RuntimeError{token: name, message: err.Error()}
+		}
+		return value, nil
+	}
+	for _, candidate := range []string{name.Lexeme, exportedName(name.Lexeme)} {
+		if method := o.value.MethodByName(candidate); method.IsValid() {
+			function, err := newGoFunction(name.Lexeme, method)
+			if err != nil {
+				return nil, &RuntimeError{token: name, message: err.Error()}
+			}
+			return function, nil
+		}
+	}
+	return nil, &RuntimeError{
+		token:   name,
+		message: fmt.Sprintf("Undefined propety '%v'", name.Lexeme),
+	}
+}
+
+func (o *GoObject) Set(name Token, value interface{}) error {
+	field, ok := o.field(name.Lexeme)
+	if !ok || !field.CanSet() {
+		return &RuntimeError{
+			token:   name,
+			message: fmt.Sprintf("Can't set field '%v' of %v", name.Lexeme, o.value.Type()),
+		}
+	}
+	converted, err := toGo(value, field.Type())
+	if err != nil {
+		return &RuntimeError{token: name, message: err.Error()}
+	}
+	field.Set(converted)
+	return nil
+}
+
+// fromGo converts a Go value to the glox value scripts see: numbers become
+// int64 or float64, functions GoFunction and everything else that isn't a string,
+// bool or nil a GoObject.
+func fromGo(name string, value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if value.CanInterface() {
+		// glox values handed back to scripts stay as they are
+		switch glox := value.Interface().(type) {
+		case GloxCallable, *GloxInstance, *GoObject, *GloxList:
+			return glox, nil
+		}
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			return float64(value.Uint()), nil
+		}
+		return int64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.Func:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return newGoFunction(name, value)
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan:
+		if value.IsNil() {
+			return nil, nil
+		}
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return fromGo(name, value.Elem())
+	case reflect.Struct:
+		// copy the struct so that its fields are settable and methods with
+		// pointer receivers can be called
+		pointer := reflect.New(value.Type())
+		pointer.Elem().Set(value)
+		value = pointer
+	}
+	return &GoObject{value: value}, nil
+}
+
+// toGo converts a glox value to the Go type a function or field expects.
+func toGo(value interface{}, t reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("Expected %v but got nil.", t)
+	}
+	if object, ok := value.(*GoObject); ok {
+		if object.value.Type().AssignableTo(t) {
+			return object.value, nil
+		}
+		if object.value.Kind() == reflect.Ptr && object.value.Elem().Type().AssignableTo(t) {
+			return object.value.Elem(), nil
+		}
+		return reflect.Value{}, fmt.Errorf("Expected %v but got %v.", t, object.value.Type())
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var number int64
+		switch v := value.(type) {
+		case int64:
+			number = v
+		case float64:
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return reflect.Value{}, fmt.Errorf("Expected an integer but got %v.", value)
+			}
+			number = int64(v)
+		default:
+			return reflect.Value{}, fmt.Errorf("Expected an integer but got %v.", value)
+		}
+		converted := reflect.New(t).Elem()
+		switch t.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if number < 0 || converted.OverflowUint(uint64(number)) {
+				return reflect.Value{}, fmt.Errorf("%v doesn't fit in %v.", value, t)
+			}
+			converted.SetUint(uint64(number))
+		default:
+			if converted.OverflowInt(number) {
+				return reflect.Value{}, fmt.Errorf("%v doesn't fit in %v.", value, t)
+			}
+			converted.SetInt(number)
+		}
+		return converted, nil
+	case reflect.Float32, reflect.Float64:
+		if !isNumber(value) {
+			return reflect.Value{}, fmt.Errorf("Expected a number but got %v.", value)
+		}
+		return reflect.ValueOf(toFloat(value)).Convert(t), nil
+	}
+	converted := reflect.ValueOf(value)
+	if converted.Type().AssignableTo(t) {
+		return converted, nil
+	}
+	if converted.Type().ConvertibleTo(t) && converted.Kind() == t.Kind() {
+		return converted.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("Expected %v but got %v.", t, value)
+}
+
+// lookupGlobal returns the value a script left in a global variable.
+func (i *Interpreter) lookupGlobal(name string) (interface{}, bool) {
+	return i.globals.lookup(name)
+}
+
+// callFunction calls a script function, bound method, class or native from
+// Go once the script has run. Arguments are converted like the results of
+// Go functions bound with defineGo; instances come back as *GloxInstance.
+func (i *Interpreter) callFunction(ctx context.Context, callee interface{}, arguments ...interface{}) (interface{}, error) {
+	function, ok := callee.(GloxCallable)
+	if !ok {
+		return nil, fmt.Errorf("Can only call functions and classes, got %v.", callee)
+	}
+	if signature := function.Signature(); !signature.accepts(len(arguments)) {
+		return nil, fmt.Errorf("%v takes %s arguments but got %d.", signature, signature.arity(), len(arguments))
+	}
+	converted := make([]interface{}, len(arguments))
+	for index, argument := range arguments {
+		value, err := fromGo(fmt.Sprint("argument ", index+1), reflect.ValueOf(argument))
+		if err != nil {
+			return nil, err
+		}
+		converted[index] = value
+	}
+
+	previous := i.ctx
+	i.ctx = ctx
+	defer func() { i.ctx = previous }()
+	name := Token{TokenType: IDENTIFIER, Lexeme: fmt.Sprint(callee)}
+	if err := i.checkCancelled(name); err != nil {
+		return nil, err
+	}
+	if err := i.enterCall(name); err != nil {
+		return nil, err
+	}
+	defer i.exitCall()
+	value, err := function.Call(i, converted)
+	if err != nil {
+		return nil, err
+	}
+	if instance, ok := value.(GloxInstance); ok {
+		return &instance, nil
+	}
+	return value, nil
+}
+
+// callGlobal calls the function, class or native a script defined under
+// name, such as an event handler.
+func (i *Interpreter) callGlobal(ctx context.Context, name string, arguments ...interface{}) (interface{}, error) {
+	callee, ok := i.lookupGlobal(name)
+	if !ok {
+		return nil, fmt.Errorf("Undefined variable '%v'.", name)
+	}
+	return i.callFunction(ctx, callee, arguments...)
+}
+
+// getField reads a field of an instance, or binds one of its methods.
+func (i *GloxInstance) getField(name string) (interface{}, error) {
+	value, err := i.Get(Token{TokenType: IDENTIFIER, Lexeme: name})
+	if instance, ok := value.(GloxInstance); ok {
+		return &instance, err
+	}
+	return value, err
+}
+
+// setField stores a Go value in a field of an instance.
+func (i *GloxInstance) setField(name string, value interface{}) error {
+	converted, err := fromGo(name, reflect.ValueOf(value))
+	if err != nil {
+		return err
+	}
+	i.Set(Token{TokenType: IDENTIFIER, Lexeme: name}, converted)
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testAccount struct {
+	Owner   string
+	Balance int
+	Limit   uint8
+	secret  string
+}
+
+func (a *testAccount) Deposit(amount int) int {
+	a.Balance += amount
+	return a.Balance
+}
+
+func (a *testAccount) Withdraw(amount int) error {
+	if amount > a.Balance {
+		return errors.New("Insufficient funds.")
+	}
+	a.Balance -= amount
+	return nil
+}
+
+func (a testAccount) Describe() string {
+	return fmt.Sprintf("%s has %d", a.Owner, a.Balance)
+}
+
+// defineAll binds each Go value as a global before the script runs.
+func defineAll(t *testing.T, values map[string]interface{}) func(*Interpreter) {
+	return func(interpreter *Interpreter) {
+		for name, value := range values {
+			if err := interpreter.defineGo(name, value); err != nil {
+				t.Fatalf("defining %s: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestDefineGo(t *testing.T) {
+	account := &testAccount{Owner: "ann", Balance: 10}
+	values := map[string]interface{}{
+		"add":  func(a, b int) int { return a + b },
+		"half": func(x float64) float64 { return x / 2 },
+		"join": func(separator string, parts ...string) string { return strings.Join(parts, separator) },
+		"check": func(ok bool) error {
+			if !ok {
+				return errors.New("Check failed.")
+			}
+			return nil
+		},
+		"small":   func(x int8) int8 { return x },
+		"nothing": func() *testAccount { return nil },
+		"account": account,
+		"point":   struct{ X, Y int }{1, 2},
+		"version": "1.0",
+		"big":     uint64(1) << 63,
+		"newAccount": func(owner string) *testAccount {
+			return &testAccount{Owner: owner}
+		},
+		"owner": func(a *testAccount) string { return a.Owner },
+	}
+	runScriptTests(t, []scriptTest{
+		{name: "function", source: "print add(1, 2);", output: "3\n"},
+		{name: "float", source: "print half(3);", output: "1.5\n"},
+		{name: "variadic", source: `print join("-", "a", "b", "c"); print join(",");`, output: "a-b-c\n\n"},
+		{name: "nil error", source: "print check(true);", output: "<nil>\n"},
+		{name: "nil pointer", source: "print nothing();", output: "<nil>\n"},
+		{name: "values", source: "print version; print big;", output: "1.0\n9.223372036854776e+18\n"},
+		{name: "fields", source: "print account.Owner; print account.balance;", output: "ann\n10\n"},
+		{name: "methods", source: "print account.deposit(5); account.Withdraw(3); print account.describe();", output: "15\nann has 12\n"},
+		{name: "set field", source: "account.balance = 100; print account.Balance;", output: "100\n"},
+		{name: "struct copy", source: "point.x = 5; print point.X + point.Y;", output: "7\n"},
+		{name: "objects round trip", source: `var other = newAccount("bob"); print owner(other);`, output: "bob\n"},
+
+		{name: "go error", source: "check(false);", err: "Check failed."},
+		{name: "method error", source: "account.withdraw(1000);", err: "Insufficient funds."},
+		{name: "not an integer", source: "add(1.5, 2);", err: "Argument 1: Expected an integer but got 1.5."},
+		{name: "wrong type", source: `add("a", 2);`, err: "Argument 1: Expected an integer but got a."},
+		{name: "overflow", source: "small(200);", err: "Argument 1: 200 doesn't fit in int8."},
+		{name: "nil for a value", source: "half(nil);", err: "Argument 1: Expected float64 but got nil."},
+		{name: "wrong object", source: "owner(point);", err: "Argument 1: Expected *main.testAccount but got"},
+		{name: "arity", source: "add(1);", err: "takes 2 arguments but got 1."},
+		{name: "unexported field", source: "print account.secret;", err: "Undefined propety 'secret'"},
+		{name: "set unexported field", source: `account.secret = "x";`, err: "Can't set field 'secret'"},
+		{name: "set wrong type", source: `account.limit = 300;`, err: "300 doesn't fit in uint8."},
+	}, defineAll(t, values))
+	if account.Balance != 100 {
+		t.Errorf("expected the script to have changed the Go value, balance is %d", account.Balance)
+	}
+}
+
+func TestDefineGoErrors(t *testing.T) {
+	interpreter := NewInterpreter()
+	err := interpreter.defineGo("pair", func() (int, int, error) { return 1, 2, nil })
+	if err == nil || err.Error() != "Go function 'pair' returns more than one value and an error." {
+		t.Errorf("expected a multiple result error, got %v", err)
+	}
+}
+
+const hostTestSource = `class Counter {
+  init(start = 0) { this.count = start; }
+  add(n = 1) { this.count = this.count + n; return this; }
+}
+var events = [];
+fun onEvent(name, payload = "none") {
+  events.push(name + ":" + payload);
+  return events.length;
+}
+fun fail() { return 1 / nil; }
+fun spin() { while (true) {} }
+var notAFunction = 1;
+`
+
+// runHost runs the script a host would load before calling into it.
+func runHost(t *testing.T) *Interpreter {
+	t.Helper()
+	var interpreter *Interpreter
+	if _, err := runSource(t, hostTestSource, func(i *Interpreter) { interpreter = i }); err != nil {
+		t.Fatal(err)
+	}
+	return interpreter
+}
+
+func TestCallIntoScript(t *testing.T) {
+	interpreter := runHost(t)
+	ctx := context.Background()
+
+	if count, err := interpreter.callGlobal(ctx, "onEvent", "click"); err != nil || count != int64(1) {
+		t.Fatalf("expected 1 event, got %v, %v", count, err)
+	}
+	if count, err := interpreter.callGlobal(ctx, "onEvent", "key", "enter"); err != nil || count != int64(2) {
+		t.Fatalf("expected 2 events, got %v, %v", count, err)
+	}
+	events, _ := interpreter.lookupGlobal("events")
+	if fmt.Sprint(events) != "[click:none, key:enter]" {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	value, err := interpreter.callGlobal(ctx, "Counter", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter, ok := value.(*GloxInstance)
+	if !ok {
+		t.Fatalf("expected an instance, got %T", value)
+	}
+	add, err := counter.getField("add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := interpreter.callFunction(ctx, add, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := result.(*GloxInstance); !ok {
+		t.Errorf("expected add to return the instance, got %T", result)
+	}
+	if _, err := interpreter.callFunction(ctx, add); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := counter.getField("count"); count != int64(8) {
+		t.Errorf("expected count 8, got %v", count)
+	}
+	if err := counter.setField("count", uint8(40)); err != nil {
+		t.Fatal(err)
+	}
+	if err := counter.setField("label", "total"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := counter.getField("count"); count != int64(40) {
+		t.Errorf("expected count 40 after setting it, got %v", count)
+	}
+	if label, _ := counter.getField("label"); label != "total" {
+		t.Errorf("expected a new field, got %v", label)
+	}
+}
+
+func TestCallIntoScriptErrors(t *testing.T) {
+	interpreter := runHost(t)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stop()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		global    string
+		arguments []interface{}
+		err       string
+		target    error
+	}{
+		{name: "undefined", global: "nope", err: "Undefined variable 'nope'."},
+		{name: "not callable", global: "notAFunction", err: "Can only call functions and classes, got 1."},
+		{name: "too few arguments", global: "onEvent", err: "onEvent(name, [payload]) takes 1 to 2 arguments but got 0."},
+		{name: "too many arguments", global: "Counter", arguments: []interface{}{1, 2}, err: "takes 0 to 1 arguments but got 2."},
+		{name: "unconvertible argument", global: "onEvent", arguments: []interface{}{func() (int, int, error) { return 0, 0, nil }},
+			err: "Go function 'argument 1' returns more than one value and an error."},
+		{name: "runtime error", global: "fail", err: "Right operand must be a number, but got nil."},
+		{name: "cancelled", ctx: cancelled, global: "onEvent", arguments: []interface{}{"late"}, target: context.Canceled},
+		{name: "timeout", ctx: timeout, global: "spin", target: context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			_, err := interpreter.callGlobal(ctx, test.global, test.arguments...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if test.target != nil && !errors.Is(err, test.target) {
+				t.Errorf("expected %v, got %v", test.target, err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected %q, got %q", test.err, err)
+			}
+		})
+	}
+	if interpreter.environment != interpreter.globals || interpreter.callDepth != 0 {
+		t.Errorf("the interpreter wasn't restored after the failed calls")
+	}
+	if count, err := interpreter.callGlobal(context.Background(), "onEvent", "after"); err != nil || count != int64(1) {
+		t.Errorf("expected the interpreter to be usable after errors, got %v, %v", count, err)
+	}
+}
@@ -1,29 +1,103 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
-	"strconv"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type Interpreter struct {
 	environment *Environment
 	globals     *Environment
 	locals      map[Expr]int
+	stdin       *bufio.Reader
+	stdout      *bufio.Writer
+	stderr      io.Writer
+	// guards stdout and stderr, which spawned calls share
+	output      *sync.Mutex
+	tracers     []Tracer
+	limits      Limits
+	ctx         context.Context
+	coercion    Coercion
+	steps       int
+	callDepth   int
+	allocated   int
+	// the generator whose body is running, if any
+	generator *GloxGenerator
+	// set once a spawned call fails; forks share it
+	taskFailed *atomic.Bool
+	// counts spawned calls that haven't finished; forks share it
+	tasks *sync.WaitGroup
+}
+
+// Tracer is notified as the interpreter runs. An error returned from a
+// hook aborts the script with that error.
+type Tracer interface {
+	statement(stmt Stmt) error
+	call(callee GloxCallable, expr ExprCall) error
+	ret(callee GloxCallable, expr ExprCall)
+}
+
+// BranchTracer is implemented by tracers that also want to know which way
+// each if, while, 'and' and 'or' condition went. Branch points are told
+// apart by their keyword or operator token.
+type BranchTracer interface {
+	branch(token Token, taken bool)
 }
 
 func NewInterpreter() *Interpreter {
 	// global env
 	global := NewEnvironment(nil)
 	global.define("clock", Time{})
+	global.define("readLine", ReadLine{})
+	global.define("Channel", ChannelNative{})
+	global.define("WaitGroup", WaitGroupNative{})
 	locals := make(map[Expr]int)
 
 	return &Interpreter{
 		globals:     &global,
 		environment: &global,
 		locals:      locals,
+		stdin:       bufio.NewReader(os.Stdin),
+		stdout:      bufio.NewWriter(os.Stdout),
+		stderr:      os.Stderr,
+		output:      &sync.Mutex{},
+		limits:      DefaultLimits(),
+		coercion:    STRICT_COERCION,
+		taskFailed:  &atomic.Bool{},
+		tasks:       &sync.WaitGroup{},
 	}
 }
 
+// setStreams replaces where print writes, where diagnostics go and where
+// natives read input from. Output is buffered until flush.
+func (i *Interpreter) setStreams(stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	i.stdin = bufio.NewReader(stdin)
+	i.stdout = bufio.NewWriter(stdout)
+	i.stderr = stderr
+}
+
+func (i *Interpreter) flush() error {
+	i.output.Lock()
+	defer i.output.Unlock()
+	return i.stdout.Flush()
+}
+
+// reportError writes a diagnostic to stderr after any output printed
+// before it.
+func (i *Interpreter) reportError(err error) {
+	i.output.Lock()
+	defer i.output.Unlock()
+	i.stdout.Flush()
+	fmt.Fprintln(i.stderr, err)
+}
+
 type RuntimeError struct {
 	token   Token
 	message string
@@ -50,6 +124,14 @@ func (i *Interpreter) visitLogicalExpr(expr ExprLogical) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if expr.Operator.TokenType == QUESTION_QUESTION {
+		i.branch(expr.Operator, left != nil)
+		if left != nil {
+			return left, nil
+		}
+		return i.evaluate(*expr.Right)
+	}
+	i.branch(expr.Operator, i.isTruthy(left))
 	if expr.Operator.TokenType == OR {
 		if i.isTruthy(left) {
 			return left, nil
@@ -78,19 +160,20 @@ func (i *Interpreter) visitUnaryExpr(expr ExprUnary) (interface{}, error) {
 	}
 	switch expr.Operator.TokenType {
 	case MINUS:
-		if err := checkNumberOperand(expr.Operator, right); err != nil {
-			return nil, err
+		return negate(expr.Operator, i.coerce(right))
+	case TILDE:
+		if number, ok := i.coerce(right).(int64); ok {
+			return ^number, nil
 		}
-		return right.(float64) * (-1), nil
+		return nil, &RuntimeError{token: expr.Operator, message: fmt.Sprintf("Operand must be an integer, but got %s.", integerTypeName(right))}
 	case BANG:
 		return !i.isTruthy(right), nil
 	}
 
-	return RuntimeError{token: expr.Operator, message: "Unknown operator."}, nil
+	return nil, &RuntimeError{token: expr.Operator, message: "Unknown operator."}
 }
 
 func (i *Interpreter) visitVariableExpr(expr ExprVariable) (interface{}, error) {
-  fmt.Printf("Interpreter: Memory address: %p\n", expr)
 	return i.lookupVariable(expr.Name, expr)
 }
 
@@ -113,58 +196,19 @@ func (i *Interpreter) isEqual(obj_a interface{}, obj_b interface{}) bool {
 	if obj_a == nil {
 		return false
 	}
-	return obj_a == obj_b
-}
-
-func checkNumberOperand(operator Token, operand interface{}) error {
-	_, ok := operand.(float64)
-	if !ok {
-		if str, ok := operand.(string); ok {
-			if _, err := strconv.ParseFloat(str, 64); err == nil {
-				ok = true
-			}
-		}
+	if isNumber(obj_a) && isNumber(obj_b) {
+		return numbersEqual(obj_a, obj_b)
 	}
-	if !ok {
-		return &RuntimeError{token: operator, message: "Operand must be a number."}
-	}
-	return nil
-}
-
-func checkIfNumberIsZero(number float64, operator Token, operand interface{}) error {
-	if number == 0 {
-		return &RuntimeError{token: operator, message: "Division by zero."}
-	}
-	return nil
+	return obj_a == obj_b
 }
 
 func checkNumberOperands(operator Token, left interface{}, right interface{}) error {
-	_, leftOk := left.(float64)
-	if !leftOk {
-		if leftStr, ok := left.(string); ok {
-			if _, err := strconv.ParseFloat(leftStr, 64); err == nil {
-				leftOk = true
-			}
-		}
-	}
-
-	_, rightOk := right.(float64)
-	if !rightOk {
-		if rightStr, ok := right.(string); ok {
-			if _, err := strconv.ParseFloat(rightStr, 64); err == nil {
-				rightOk = true
-			}
-		}
-	}
-
-	if !leftOk {
-		return &RuntimeError{token: operator, message: fmt.Sprintf("Left operand must be a number, but got %T.", left)}
+	if !isNumber(left) {
+		return &RuntimeError{token: operator, message: fmt.Sprintf("Left operand must be a number, but got %s.", typeName(left))}
 	}
-	if !rightOk {
-		return &RuntimeError{token: operator, message: fmt.Sprintf("Right operand must be a number, but got %T.", right)}
+	if !isNumber(right) {
+		return &RuntimeError{token: operator, message: fmt.Sprintf("Right operand must be a number, but got %s.", typeName(right))}
 	}
-
-	// Use leftValue and rightValue as the coerced numbers
 	return nil
 }
 
@@ -177,72 +221,100 @@ func (i *Interpreter) visitBinaryExpr(expr ExprBinary) (interface{}, error) {
 	if errRight != nil {
 		return nil, errRight
 	}
-	switch expr.Operator.TokenType {
-	case MINUS:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
-		}
-		return left.(float64) - right.(float64), nil
-	case SLASH:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
-		}
-		if err := checkIfNumberIsZero(right.(float64), expr.Operator, right); err != nil {
-			return nil, err
-		}
-		return left.(float64) / right.(float64), nil
-	case STAR:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
-		}
-		return left.(float64) * right.(float64), nil
+	return i.binary(expr.Operator, left, right)
+}
+
+// binary applies a binary operator to two values; compound assignments
+// share it with binary expressions. Operands are converted according to
+// the interpreter's Coercion.
+func (i *Interpreter) binary(operator Token, left interface{}, right interface{}) (interface{}, error) {
+	switch operator.TokenType {
+	case EQUAL_EQUAL, BANG_EQUAL:
 	case PLUS:
-		if leftStr, ok := left.(string); ok {
-			if rightStr, ok := right.(string); ok {
-				return leftStr + rightStr, nil
-			}
-		}
-		if leftFloat, ok := left.(float64); ok {
-			if rightFloat, ok := right.(float64); ok {
-				return leftFloat + rightFloat, nil
-			}
+		if result, ok, err := i.concatenate(operator, left, right); ok {
+			return result, err
 		}
-	case GREATER:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
-		}
-		return left.(float64) > right.(float64), nil
-	case GREATER_EQUAL:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
-		}
-		return left.(float64) >= right.(float64), nil
-	case LESS:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
+		fallthrough
+	default:
+		left, right = i.coerce(left), i.coerce(right)
+	}
+	switch operator.TokenType {
+	case MINUS, SLASH, STAR, TILDE_SLASH, PERCENT:
+		return i.arithmetic(operator, left, right)
+	case PLUS:
+		if isNumber(left) && isNumber(right) {
+			return i.arithmetic(operator, left, right)
 		}
-		return left.(float64) < right.(float64), nil
-	case LESS_EQUAL:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
-			return nil, err
+		return nil, &RuntimeError{
+			token:   operator,
+			message: fmt.Sprintf("Operands must be two numbers or two strings, but got %s and %s.", typeName(left), typeName(right)),
 		}
-		return left.(float64) <= right.(float64), nil
+	case STAR_STAR:
+		return power(operator, left, right)
+	case AMPERSAND, PIPE, CARET, LESS_LESS, GREATER_GREATER:
+		return bitwise(operator, left, right)
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		return compare(operator, left, right)
 	case BANG_EQUAL:
 		return !i.isEqual(left, right), nil
 	case EQUAL_EQUAL:
 		return i.isEqual(left, right), nil
 	}
-	return RuntimeError{token: expr.Operator, message: "Unknown operator."}, nil
+	return nil, &RuntimeError{token: operator, message: "Unknown operator."}
 }
 
-func (i *Interpreter) visitCallExpr(expr ExprCall) (interface{}, error) {
-	callee, err := i.evaluate(*expr.Callee)
+func (i *Interpreter) visitConditionalExpr(expr ExprConditional) (interface{}, error) {
+	condition, err := i.evaluate(*expr.Condition)
 	if err != nil {
 		return nil, err
 	}
+	i.branch(expr.Question, i.isTruthy(condition))
+	if i.isTruthy(condition) {
+		return i.evaluate(*expr.Then)
+	}
+	return i.evaluate(*expr.Else)
+}
+
+func (i *Interpreter) visitCallExpr(expr ExprCall) (interface{}, error) {
+	value, _, err := i.callLink(expr)
+	return value, err
+}
+
+// callLink is visitCallExpr for a call in a chain; skipped is true when a
+// '?.' on nil before it skips the call.
+func (i *Interpreter) callLink(expr ExprCall) (value interface{}, skipped bool, err error) {
+	function, arguments, err := i.prepareCall(expr)
+	if function == nil {
+		return nil, err == nil, err
+	}
+	value, err = i.call(function, expr, arguments)
+	return value, false, err
+}
+
+// visitSpawnExpr evaluates the callee and arguments before the call starts
+// on a goroutine of its own.
+func (i *Interpreter) visitSpawnExpr(expr ExprSpawn) (interface{}, error) {
+	function, arguments, err := i.prepareCall(expr.Call)
+	if function == nil {
+		return nil, err
+	}
+	if err := i.checkCancelled(expr.Keyword); err != nil {
+		return nil, err
+	}
+	return i.spawn(function, expr.Call, arguments), nil
+}
+
+// prepareCall evaluates the callee and the arguments of a call and puts
+// the arguments in parameter order. The function is nil when there is an
+// error, or when the call is skipped because of a '?.' on nil.
+func (i *Interpreter) prepareCall(expr ExprCall) (GloxCallable, []interface{}, error) {
+	callee, skipped, err := i.evaluateLink(*expr.Callee)
+	if err != nil || skipped {
+		return nil, nil, err
+	}
 	function, ok := callee.(GloxCallable)
 	if !ok {
-		return nil, &RuntimeError{
+		return nil, nil, &RuntimeError{
 			token:   expr.Paren,
 			message: "Can only call functions and classes",
 		}
@@ -251,33 +323,131 @@ func (i *Interpreter) visitCallExpr(expr ExprCall) (interface{}, error) {
 	for _, argument := range expr.Arguments {
 		_arg, err := i.evaluate(*argument)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		arguments = append(arguments, _arg)
 	}
-	if len(arguments) != function.Arity() {
-		message := fmt.Sprintf("Expected %d arguments but got %d", function.Arity(), len(arguments))
-		return nil, &RuntimeError{
-			token:   expr.Paren,
-			message: message,
+	arguments, err = function.Signature().bind(expr.Paren, expr.Names, arguments)
+	if err != nil {
+		return nil, nil, err
+	}
+	return function, arguments, nil
+}
+
+func (i *Interpreter) call(function GloxCallable, expr ExprCall, arguments []interface{}) (interface{}, error) {
+	if _, ok := function.(GloxClass); ok {
+		if err := i.allocate(instanceSize, expr.Paren); err != nil {
+			return nil, err
 		}
 	}
-	return function.Call(i, arguments)
+	if err := i.checkCancelled(expr.Paren); err != nil {
+		return nil, err
+	}
+	if err := i.enterCall(expr.Paren); err != nil {
+		return nil, err
+	}
+	defer i.exitCall()
+	for index, tracer := range i.tracers {
+		if err := tracer.call(function, expr); err != nil {
+			// the call never happens, so the tracers told about it already
+			// have to pop it again
+			for _, notified := range i.tracers[:index] {
+				notified.ret(function, expr)
+			}
+			return nil, err
+		}
+	}
+	var value interface{}
+	var err error
+	if native, ok := function.(callableAt); ok {
+		value, err = native.callAt(i, expr.Paren, arguments)
+	} else {
+		value, err = function.Call(i, arguments)
+	}
+	for _, tracer := range i.tracers {
+		tracer.ret(function, expr)
+	}
+	return value, err
+}
+
+// calleeName is how a call shows up in traces: the function or class name,
+// or the name it was called through for natives.
+func calleeName(callee GloxCallable, expr ExprCall) string {
+	switch c := callee.(type) {
+	case GloxFunction:
+		return c.Declaration.Name.Lexeme
+	case GloxClass:
+		return c.Name
+	}
+	if variable, ok := (*expr.Callee).(ExprVariable); ok {
+		return variable.Name.Lexeme
+	}
+	return fmt.Sprint(callee)
 }
 
 func (i *Interpreter) visitGetExpr(expr ExprGet) (interface{}, error) {
-	obj, err := i.evaluate(*expr.Object)
-	if err != nil {
-		return nil, err
+	value, _, err := i.getLink(expr)
+	return value, err
+}
+
+// getLink is visitGetExpr for a property access in a chain.
+func (i *Interpreter) getLink(expr ExprGet) (value interface{}, skipped bool, err error) {
+	obj, skipped, err := i.evaluateLink(*expr.Object)
+	if err != nil || skipped {
+		return nil, skipped, err
+	}
+	if obj == nil && expr.Optional {
+		return nil, true, nil
 	}
+	value, err = i.getProperty(obj, expr.Name)
+	return value, false, err
+}
+
+// evaluateLink evaluates the object of a property access or subscript, or
+// the callee of a call. A '?.' on nil skips the rest of the chain it is
+// in, so a?.b.c() is nil rather than an error when a is nil; skipped
+// tells the links after it.
+func (i *Interpreter) evaluateLink(expr Expr) (interface{}, bool, error) {
+	switch expr.(type) {
+	case ExprGet, ExprCall, ExprIndex:
+		// counted like evaluate would
+		if i.step() {
+			return nil, false, i.stepLimitError(exprLine(expr))
+		}
+	default:
+		value, err := i.evaluate(expr)
+		return value, false, err
+	}
+	switch link := expr.(type) {
+	case ExprGet:
+		return i.getLink(link)
+	case ExprCall:
+		return i.callLink(link)
+	}
+	return i.indexLink(expr.(ExprIndex))
+}
+
+func (i *Interpreter) getProperty(obj interface{}, name Token) (interface{}, error) {
 	switch instance := obj.(type) {
 	case GloxInstance:
-		return instance.Get(expr.Name)
+		return instance.Get(name)
 	case *GloxInstance:
-		return instance.Get(expr.Name)
+		return instance.Get(name)
+	case *GoObject:
+		return instance.Get(name)
+	case *GloxList:
+		return instance.Get(name)
+	case *GloxGenerator:
+		return instance.Get(name)
+	case *GloxChannel:
+		return instance.Get(name)
+	case *GloxTask:
+		return instance.Get(name)
+	case *GloxWaitGroup:
+		return instance.Get(name)
 	default:
 		return nil, &RuntimeError{
-			token:   expr.Name,
+			token:   name,
 			message: "Only instances have properties",
 		}
 	}
@@ -288,24 +458,114 @@ func (i *Interpreter) visitSetExpr(expr ExprSet) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkHasFields(obj, expr.Name); err != nil {
+		return nil, err
+	}
+	value, err := i.evaluate(*expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	return value, i.setProperty(obj, expr.Name, value)
+}
+
+func checkHasFields(obj interface{}, name Token) error {
+	switch obj.(type) {
+	case GloxInstance, *GloxInstance, *GoObject:
+		return nil
+	}
+	return &RuntimeError{
+		token:   name,
+		message: "Only instances have fields",
+	}
+}
+
+func (i *Interpreter) setProperty(obj interface{}, name Token, value interface{}) error {
 	var instance *GloxInstance
 	switch inst := obj.(type) {
 	case GloxInstance:
 		instance = &inst
 	case *GloxInstance:
 		instance = inst
+	case *GoObject:
+		return inst.Set(name, value)
 	default:
-		return nil, &RuntimeError{
-			token:   expr.Name,
-			message: "Only instances have fields",
+		return checkHasFields(obj, name)
+	}
+	if _, ok := instance.field(name.Lexeme); !ok {
+		if err := i.allocate(fieldSize+len(name.Lexeme), name); err != nil {
+			return err
 		}
 	}
-	value, err := i.evaluate(*expr.Value)
-	if err != nil {
-		return nil, err
+	instance.Set(name, value)
+	return nil
+}
+
+func (i *Interpreter) visitUpdateExpr(expr ExprUpdate) (interface{}, error) {
+	switch target := expr.Target.(type) {
+	case ExprVariable:
+		current, err := i.lookupVariable(target.Name, target)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(*expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		result, err := i.binary(expr.Operator, current, value)
+		if err != nil {
+			return nil, err
+		}
+		if distance, ok := i.locals[target]; ok {
+			i.environment.assignAt(distance, target.Name, result)
+		} else if err := i.globals.assign(target.Name, result); err != nil {
+			return nil, err
+		}
+		return updated(expr, current, result), nil
+	case ExprGet:
+		obj, err := i.evaluate(*target.Object)
+		if err != nil {
+			return nil, err
+		}
+		current, err := i.getProperty(obj, target.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(*expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		result, err := i.binary(expr.Operator, current, value)
+		if err != nil {
+			return nil, err
+		}
+		return updated(expr, current, result), i.setProperty(obj, target.Name, result)
+	case ExprIndex:
+		list, index, err := i.evaluateIndex(*target.Object, target.Bracket, *target.Index)
+		if err != nil {
+			return nil, err
+		}
+		current, err := list.get(target.Bracket, index)
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(*expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		result, err := i.binary(expr.Operator, current, value)
+		if err != nil {
+			return nil, err
+		}
+		return updated(expr, current, result), list.set(target.Bracket, index, result)
 	}
-	instance.Set(expr.Name, value)
-	return value, nil
+	return nil, &RuntimeError{token: expr.Operator, message: "Invalid assignment target."}
+}
+
+func updated(expr ExprUpdate, before interface{}, after interface{}) interface{} {
+	if expr.Postfix {
+		return before
+	}
+	return after
 }
 
 func (i *Interpreter) visitSuperExpr(expr ExprSuper) (interface{}, error) {
@@ -316,6 +576,94 @@ func (i *Interpreter) visitSuperExpr(expr ExprSuper) (interface{}, error) {
   return method.Bind(object), nil
 }
 
+func (i *Interpreter) visitInterpolationExpr(expr ExprInterpolation) (interface{}, error) {
+	var text strings.Builder
+	for _, part := range expr.Parts {
+		value, err := i.evaluate(*part)
+		if err != nil {
+			return nil, err
+		}
+		text.WriteString(stringify(value))
+	}
+	if err := i.allocate(text.Len(), expr.Start); err != nil {
+		return nil, err
+	}
+	return text.String(), nil
+}
+
+func (i *Interpreter) visitListExpr(expr ExprList) (interface{}, error) {
+	elements := make([]interface{}, len(expr.Elements))
+	for index, element := range expr.Elements {
+		value, err := i.evaluate(*element)
+		if err != nil {
+			return nil, err
+		}
+		elements[index] = value
+	}
+	if err := i.allocate(elementSize*len(elements), expr.Bracket); err != nil {
+		return nil, err
+	}
+	return NewGloxList(elements), nil
+}
+
+func (i *Interpreter) visitIndexExpr(expr ExprIndex) (interface{}, error) {
+	value, _, err := i.indexLink(expr)
+	return value, err
+}
+
+// indexLink is visitIndexExpr for a subscript in a chain.
+func (i *Interpreter) indexLink(expr ExprIndex) (value interface{}, skipped bool, err error) {
+	obj, skipped, err := i.evaluateLink(*expr.Object)
+	if err != nil || skipped {
+		return nil, skipped, err
+	}
+	list, index, err := i.listIndex(obj, expr.Bracket, *expr.Index)
+	if err != nil {
+		return nil, false, err
+	}
+	value, err = list.get(expr.Bracket, index)
+	return value, false, err
+}
+
+func (i *Interpreter) visitIndexSetExpr(expr ExprIndexSet) (interface{}, error) {
+	list, index, err := i.evaluateIndex(*expr.Object, expr.Bracket, *expr.Index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := i.evaluate(*expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	return value, list.set(expr.Bracket, index, value)
+}
+
+// evaluateIndex evaluates the list and the index of list[index].
+func (i *Interpreter) evaluateIndex(object Expr, bracket Token, index Expr) (*GloxList, interface{}, error) {
+	value, err := i.evaluate(object)
+	if err != nil {
+		return nil, nil, err
+	}
+	return i.listIndex(value, bracket, index)
+}
+
+// listIndex checks that value is a list and evaluates the index into it.
+func (i *Interpreter) listIndex(value interface{}, bracket Token, index Expr) (*GloxList, interface{}, error) {
+	list, ok := value.(*GloxList)
+	if !ok {
+		return nil, nil, &RuntimeError{token: bracket, message: fmt.Sprintf("Can only index lists, but got %s.", typeName(value))}
+	}
+	at, err := i.evaluate(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return list, at, nil
+}
+
+// stringify is how print shows a value.
+func stringify(value interface{}) string {
+	return fmt.Sprint(value)
+}
+
 func (i *Interpreter) visitStmtExpression(stmt StmtExpression) error {
 	var _, err = i.evaluate(*stmt.Expression)
 	if err != nil {
@@ -332,10 +680,14 @@ func (i *Interpreter) visitStmtFunction(stmt StmtFunction) error {
 
 func (i *Interpreter) visitStmtWhile(stmt StmtWhile) error {
 	for {
+		if err := i.checkCancelled(stmt.Keyword); err != nil {
+			return err
+		}
 		condition, err := i.evaluate(stmt.Condition)
 		if err != nil {
 			return err
 		}
+		i.branch(stmt.Keyword, i.isTruthy(condition))
 		if !i.isTruthy(condition) {
 			break
 		}
@@ -346,11 +698,108 @@ func (i *Interpreter) visitStmtWhile(stmt StmtWhile) error {
 	return nil
 }
 
+func (i *Interpreter) visitStmtForIn(stmt StmtForIn) error {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return err
+	}
+	next, err := i.iterate(stmt.Keyword, iterable)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := i.checkCancelled(stmt.Keyword); err != nil {
+			return err
+		}
+		value, ok, err := next()
+		if err != nil {
+			return err
+		}
+		i.branch(stmt.Keyword, ok)
+		if !ok {
+			return nil
+		}
+		env := NewEnvironment(i.environment)
+		env.define(stmt.Name.Lexeme, value)
+		if err := i.executeBlock([]*Stmt{&stmt.Body}, &env); err != nil {
+			return err
+		}
+	}
+}
+
+func (i *Interpreter) visitStmtSelect(stmt StmtSelect) error {
+	var cases []reflect.SelectCase
+	// the statement case of each reflect case
+	var indexes []int
+	for index, selectCase := range stmt.Cases {
+		if selectCase.Channel == nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+			indexes = append(indexes, index)
+			continue
+		}
+		value, err := i.evaluate(selectCase.Channel)
+		if err != nil {
+			return err
+		}
+		channel, ok := value.(*GloxChannel)
+		if !ok {
+			return &RuntimeError{token: selectCase.Operation, message: fmt.Sprintf("Can only select on channels, but got %s.", typeName(value))}
+		}
+		if selectCase.Value == nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel.channel)})
+		} else {
+			sent, err := i.evaluate(selectCase.Value)
+			if err != nil {
+				return err
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(channel.channel), Send: reflect.ValueOf(&sent).Elem()})
+		}
+		indexes = append(indexes, index)
+	}
+	if done := i.done(); done != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+	}
+	chosen, received, err := selectChannels(stmt.Keyword, cases)
+	if err != nil {
+		return err
+	}
+	if chosen == len(indexes) {
+		return i.checkCancelled(stmt.Keyword)
+	}
+	for index, selectCase := range stmt.Cases {
+		i.branch(selectCase.Keyword, index == indexes[chosen])
+	}
+	selectCase := stmt.Cases[indexes[chosen]]
+	env := NewEnvironment(i.environment)
+	if selectCase.Name != nil {
+		var value interface{}
+		if received.IsValid() {
+			value = received.Interface()
+		}
+		env.define(selectCase.Name.Lexeme, value)
+	}
+	return i.executeBlock([]*Stmt{&selectCase.Body}, &env)
+}
+
+func (i *Interpreter) visitStmtYield(stmt StmtYield) error {
+	var value interface{}
+	if stmt.Value != nil {
+		var err error
+		value, err = i.evaluate(stmt.Value)
+		if err != nil {
+			return err
+		}
+	}
+	i.generator.yield(value)
+	return nil
+}
+
 func (i *Interpreter) visitStmtIf(stmt StmtIf) error {
 	var value, err = i.evaluate(stmt.Condition)
 	if err != nil {
 		return err
 	}
+	i.branch(stmt.Keyword, i.isTruthy(value))
 	if i.isTruthy(value) {
 		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
@@ -359,21 +808,52 @@ func (i *Interpreter) visitStmtIf(stmt StmtIf) error {
 	return nil
 }
 
-func (i *Interpreter) visitStmtPrint(stmt StmtPrint) error {
-	var value, err = i.evaluate(stmt.Expression)
+func (i *Interpreter) visitStmtMatch(stmt StmtMatch) error {
+	subject, err := i.evaluate(stmt.Subject)
 	if err != nil {
 		return err
 	}
-	fmt.Println(value)
+	for _, matchCase := range stmt.Cases {
+		env := NewEnvironment(i.environment)
+		matched := len(matchCase.Patterns) == 0
+		for _, pattern := range matchCase.Patterns {
+			if matched, err = i.matchPattern(pattern, subject, &env); err != nil {
+				return err
+			}
+			if matched {
+				break
+			}
+			env = NewEnvironment(i.environment)
+		}
+		i.branch(matchCase.Keyword, matched)
+		if matched {
+			return i.executeBlock([]*Stmt{&matchCase.Body}, &env)
+		}
+	}
 	return nil
 }
 
-func (i *Interpreter) visitStmtReturn(stmt StmtReturn) error {
-	value, err := i.evaluate(stmt.Value)
+func (i *Interpreter) visitStmtPrint(stmt StmtPrint) error {
+	var value, err = i.evaluate(stmt.Expression)
 	if err != nil {
 		return err
 	}
+	text := stringify(value)
+	i.output.Lock()
+	defer i.output.Unlock()
+	_, err = fmt.Fprintln(i.stdout, text)
+	return err
+}
 
+func (i *Interpreter) visitStmtReturn(stmt StmtReturn) error {
+	var value interface{}
+	if stmt.Value != nil {
+		var err error
+		value, err = i.evaluate(stmt.Value)
+		if err != nil {
+			return err
+		}
+	}
 	return Return{Value: value}
 }
 
@@ -386,10 +866,54 @@ func (i *Interpreter) visitStmtVarDeclaration(stmt StmtVarDeclaration) error {
 		}
 		value = val
 	}
-	i.environment.define(stmt.Name.Lexeme, value)
+	if stmt.Const {
+		i.environment.defineConst(stmt.Name.Lexeme, value)
+	} else {
+		i.environment.define(stmt.Name.Lexeme, value)
+	}
+	return nil
+}
+
+func (i *Interpreter) visitStmtDestructure(stmt StmtDestructure) error {
+	value, err := i.evaluate(stmt.Initializer)
+	if err != nil {
+		return err
+	}
+	values, err := i.destructure(stmt.Target, value)
+	if err != nil {
+		return err
+	}
+	for index, target := range stmt.Target.Targets {
+		if stmt.Const {
+			i.environment.defineConst(target.Name.Lexeme, values[index])
+		} else {
+			i.environment.define(target.Name.Lexeme, values[index])
+		}
+	}
 	return nil
 }
 
+// visitDestructureExpr evaluates to the value destructured, like an
+// assignment evaluates to the value assigned.
+func (i *Interpreter) visitDestructureExpr(expr ExprDestructure) (interface{}, error) {
+	value, err := i.evaluate(*expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	values, err := i.destructure(expr.Target, value)
+	if err != nil {
+		return nil, err
+	}
+	for index, target := range expr.Target.Targets {
+		if distance, ok := i.locals[target]; ok {
+			i.environment.assignAt(distance, target.Name, values[index])
+		} else if err := i.globals.assign(target.Name, values[index]); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
 func (i *Interpreter) visitAssignExpr(expr ExprAssign) (interface{}, error) {
 	var value, error = i.evaluate(*expr.Value)
 	if error != nil {
@@ -398,8 +922,8 @@ func (i *Interpreter) visitAssignExpr(expr ExprAssign) (interface{}, error) {
 	distance, ok := i.locals[expr]
 	if ok {
 		i.environment.assignAt(distance, expr.Name, value)
-	} else {
-		i.globals.assign(expr.Name, value)
+	} else if err := i.globals.assign(expr.Name, value); err != nil {
+		return nil, err
 	}
 	return value, nil
 }
@@ -410,9 +934,25 @@ func (i *Interpreter) visitThisExpr(expr ExprThis) (interface{}, error) {
 }
 
 func (i *Interpreter) execute(stmt Stmt) error {
+	if i.step() {
+		return i.stepLimitError(stmtLine(stmt))
+	}
+	for _, tracer := range i.tracers {
+		if err := tracer.statement(stmt); err != nil {
+			return err
+		}
+	}
 	return stmt.accept(i)
 }
 
+func (i *Interpreter) branch(token Token, taken bool) {
+	for _, tracer := range i.tracers {
+		if branchTracer, ok := tracer.(BranchTracer); ok {
+			branchTracer.branch(token, taken)
+		}
+	}
+}
+
 func (i *Interpreter) resolve(expr Expr, depth int) {
 	i.locals[expr] = depth
 }
@@ -457,6 +997,7 @@ func (i *Interpreter) visitStmtClass(stmt StmtClass) error {
 	case nil:
 		klass = NewGloxClass(stmt.Name.Lexeme, methods, nil)
 	default:
+		i.environment = i.environment.enclosing
 		return &RuntimeError{
 			token:   stmt.Superclass.Name,
 			message: "Superclass must be a class",
@@ -465,9 +1006,7 @@ func (i *Interpreter) visitStmtClass(stmt StmtClass) error {
   if stmt.Superclass != nil {
     i.environment = i.environment.enclosing
   }
-	if err := i.environment.assign(stmt.Name, klass); err != nil {
-		return err
-	}
+	i.environment.defineConst(stmt.Name.Lexeme, klass)
 	return nil
 }
 
@@ -494,6 +1033,9 @@ func (i *Interpreter) lookupVariable(name Token, expr Expr) (interface{}, error)
 }
 
 func (i *Interpreter) evaluate(expr Expr) (interface{}, error) {
+	if i.step() {
+		return nil, i.stepLimitError(exprLine(expr))
+	}
 	return expr.accept(i)
 }
 
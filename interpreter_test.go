@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStreams(t *testing.T) {
+	tests := []struct {
+		name   string
+		stdin  string
+		source string
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "print",
+			source: `print "hello"; print 1 + 2; print nil;`,
+			stdout: "hello\n3\n<nil>\n",
+		},
+		{
+			name:   "read lines",
+			stdin:  "first\r\nsecond\nlast",
+			source: "print readLine(); print readLine(); print readLine(); print readLine();",
+			stdout: "first\nsecond\nlast\n<nil>\n",
+		},
+		{
+			name:   "echo until the end of the input",
+			stdin:  "a\nb\n",
+			source: "var line = readLine();\nwhile (line != nil) {\n  print \"> \" + line;\n  line = readLine();\n}",
+			stdout: "> a\n> b\n",
+		},
+		{
+			name:   "runtime error",
+			source: "print 1;\nprint -\"a\";",
+			stdout: "1\n",
+			stderr: "Error at '-': Operand must be a number, but got string.\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statements := parseSource(t, test.source)
+			var stdout, stderr bytes.Buffer
+			interpreter := NewInterpreter()
+			interpreter.setStreams(strings.NewReader(test.stdin), &stdout, &stderr)
+			resolver := NewResolver(interpreter)
+			if err := resolver.resolveStatements(statements); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := interpreter.interpret(statements); err != nil {
+				interpreter.reportError(err)
+			}
+			interpreter.flush()
+			if stdout.String() != test.stdout {
+				t.Errorf("expected stdout %q, got %q", test.stdout, stdout.String())
+			}
+			if !strings.HasSuffix(stderr.String(), test.stderr) || (test.stderr == "") != (stderr.Len() == 0) {
+				t.Errorf("expected stderr ending %q, got %q", test.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestOutputIsBuffered(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	interpreter := NewInterpreter()
+	interpreter.setStreams(strings.NewReader("input\n"), &stdout, &stderr)
+	resolver := NewResolver(interpreter)
+	statements := parseSource(t, `print "prompt"; var answer = readLine(); print answer;`)
+	if err := resolver.resolveStatements(statements); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interpreter.interpret(statements); err != nil {
+		t.Fatal(err)
+	}
+	// readLine flushes the prompt; the last line waits for flush
+	if stdout.String() != "prompt\n" {
+		t.Errorf("expected only the prompt before flushing, got %q", stdout.String())
+	}
+	interpreter.flush()
+	if stdout.String() != "prompt\ninput\n" {
+		t.Errorf("expected everything after flushing, got %q", stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("unexpected diagnostics %q", stderr.String())
+	}
+}
+
+func TestConditionalsAndChaining(t *testing.T) {
+	const classes = "class A { init() { this.b = nil; this.l = [1]; } m() { return this; } }\nvar a = A();\nvar n = nil;\n"
+	runScriptTests(t, []scriptTest{
+		{name: "conditional", source: `print 1 > 2 ? "yes" : "no";`, output: "no\n"},
+		{name: "conditional is right associative", source: "print true ? 1 : false ? 2 : 3; print false ? 1 : false ? 2 : 3;", output: "1\n3\n"},
+		{name: "only the chosen branch runs", source: `fun f(x) { print x; return x; } print true ? f(1) : f(2);`, output: "1\n1\n"},
+		{name: "nil coalescing", source: classes + `print a.b ?? "default"; print n ?? n ?? 3;`, output: "default\n3\n"},
+		{name: "only nil is replaced", source: "print false ?? 1; print 0 ?? 1;", output: "false\n0\n"},
+		{name: "optional get", source: classes + "print n?.b; print a?.b;", output: "<nil>\n<nil>\n"},
+		{name: "skips the rest of the chain", source: classes + "print n?.b.c; print n?.m().b.c; print n?.l[0];", output: "<nil>\n<nil>\n<nil>\n"},
+		{name: "skips arguments", source: classes + `fun side() { print "side"; return 1; } print n?.x(side());`, output: "<nil>\n"},
+		{name: "on a value", source: classes + "print a?.m()?.l[0];", output: "1\n"},
+		{name: "with coalescing", source: classes + `print n?.b.c ?? "none";`, output: "none\n"},
+
+		{name: "plain get on nil", source: classes + "print a.b?.c; print n.b;", output: "<nil>\n", err: "Error at 'b': Only instances have properties"},
+		{name: "grouping ends the chain", source: classes + "print (n?.b).c;", err: "Error at 'c': Only instances have properties"},
+	})
+}
+
+func TestOptionalChainAssignment(t *testing.T) {
+	for _, source := range []string{"n?.b = 1;", "n?.b += 1;", "n?.b++;", "--n?.b;", "n?.l[0] = 1;", "n?.m().b = 1;"} {
+		errors := parseErrors("var n = nil; " + source)
+		if len(errors) == 0 || !strings.Contains(errors[0], "Invalid assignment target.") {
+			t.Errorf("%s: expected an invalid assignment target, got %v", source, errors)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import "fmt"
+
+// Limits bounds the resources a script may use. A zero field means no
+// limit.
+type Limits struct {
+	// statements and expressions evaluated
+	MaxSteps int
+	// nested calls of functions, methods, classes and natives
+	MaxCallDepth int
+	// approximate bytes allocated for strings and instances
+	MaxAllocation int
+}
+
+// The default call depth keeps runaway recursion well clear of the Go
+// stack limit, which would otherwise crash the process.
+const DEFAULT_MAX_CALL_DEPTH = 10000
+
+func DefaultLimits() Limits {
+	return Limits{MaxCallDepth: DEFAULT_MAX_CALL_DEPTH}
+}
+
+type LimitKind string
+
+const (
+	STEP_LIMIT       LimitKind = "step"
+	CALL_DEPTH_LIMIT LimitKind = "call depth"
+	ALLOCATION_LIMIT LimitKind = "allocation"
+)
+
+// Approximate sizes charged against MaxAllocation.
+const (
+	instanceSize = 64
+	fieldSize    = 32
+	elementSize  = 16
+)
+
+// LimitError stops a script that exceeded one of its Limits. Hosts can tell
+// it apart from other failures with errors.As.
+type LimitError struct {
+	line  int
+	Kind  LimitKind
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	message := fmt.Sprintf("Error: %s limit of %d exceeded.", e.Kind, e.Limit)
+	// literals carry no position
+	if e.line == 0 {
+		return message
+	}
+	return fmt.Sprintf("[line %d] %s", e.line, message)
+}
+
+// step counts one statement or expression and reports whether the budget
+// is used up. The line for the error is only worked out once it is.
+func (i *Interpreter) step() bool {
+	i.steps++
+	return i.limits.MaxSteps > 0 && i.steps > i.limits.MaxSteps
+}
+
+func (i *Interpreter) stepLimitError(line int) error {
+	return &LimitError{line: line, Kind: STEP_LIMIT, Limit: i.limits.MaxSteps}
+}
+
+func (i *Interpreter) enterCall(token Token) error {
+	i.callDepth++
+	if i.limits.MaxCallDepth > 0 && i.callDepth > i.limits.MaxCallDepth {
+		i.callDepth--
+		return &LimitError{line: token.Line, Kind: CALL_DEPTH_LIMIT, Limit: i.limits.MaxCallDepth}
+	}
+	return nil
+}
+
+func (i *Interpreter) exitCall() {
+	i.callDepth--
+}
+
+func (i *Interpreter) allocate(size int, token Token) error {
+	i.allocated += size
+	if i.limits.MaxAllocation > 0 && i.allocated > i.limits.MaxAllocation {
+		return &LimitError{line: token.Line, Kind: ALLOCATION_LIMIT, Limit: i.limits.MaxAllocation}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func withLimits(limits Limits) func(*Interpreter) {
+	return func(interpreter *Interpreter) {
+		interpreter.limits = limits
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		source string
+		output string
+		kind   LimitKind
+		err    string
+	}{
+		{
+			name:   "steps",
+			limits: Limits{MaxSteps: 100},
+			source: "print 1;\nwhile (true) {}",
+			output: "1\n",
+			kind:   STEP_LIMIT,
+			err:    "Error: step limit of 100 exceeded.",
+		},
+		{
+			name:   "call depth",
+			limits: Limits{MaxCallDepth: 50},
+			source: "fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			kind:   CALL_DEPTH_LIMIT,
+			err:    "[line 2] Error: call depth limit of 50 exceeded.",
+		},
+		{
+			name:   "default call depth",
+			limits: DefaultLimits(),
+			source: "fun f() { return f(); }\nf();",
+			kind:   CALL_DEPTH_LIMIT,
+			err:    "call depth limit of 10000 exceeded.",
+		},
+		{
+			name:   "string allocation",
+			limits: Limits{MaxAllocation: 1000},
+			source: "var s = \"\";\nwhile (true) s = s + \"abcdefgh\";",
+			kind:   ALLOCATION_LIMIT,
+			err:    "[line 2] Error: allocation limit of 1000 exceeded.",
+		},
+		{
+			name:   "instance allocation",
+			limits: Limits{MaxAllocation: 1000},
+			source: "class A {}\nvar all = [];\nwhile (true) all.push(A());",
+			kind:   ALLOCATION_LIMIT,
+			err:    "allocation limit of 1000 exceeded.",
+		},
+		{
+			name:   "within limits",
+			limits: Limits{MaxSteps: 1000, MaxCallDepth: 10, MaxAllocation: 1000},
+			source: "fun f(n) { if (n == 0) return \"done\"; return f(n - 1); }\nprint f(5);",
+			output: "done\n",
+		},
+		{
+			name:   "zero is unlimited",
+			limits: Limits{},
+			source: "var i = 0;\nwhile (i < 10000) i = i + 1;\nprint i;",
+			output: "10000\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := runSource(t, test.source, withLimits(test.limits))
+			if output != test.output {
+				t.Errorf("expected output %q, got %q", test.output, output)
+			}
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var limitError *LimitError
+			if !errors.As(err, &limitError) {
+				t.Fatalf("expected a LimitError, got %v", err)
+			}
+			if limitError.Kind != test.kind {
+				t.Errorf("expected the %s limit, got %s", test.kind, limitError.Kind)
+			}
+			if !strings.HasSuffix(err.Error(), test.err) {
+				t.Errorf("expected error ending %q, got %q", test.err, err)
+			}
+		})
+	}
+}
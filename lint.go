@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+type DeclarationKind string
+
+const (
+	VARIABLE_DECLARATION  DeclarationKind = "variable"
+	CONSTANT_DECLARATION  DeclarationKind = "constant"
+	PARAMETER_DECLARATION DeclarationKind = "parameter"
+	FUNCTION_DECLARATION  DeclarationKind = "function"
+	CLASS_DECLARATION     DeclarationKind = "class"
+	METHOD_DECLARATION    DeclarationKind = "method"
+)
+
+// Declaration is what the resolver knows about a name bound in a scope.
+// Signature is nil when the name is not known to hold a callable.
+type Declaration struct {
+	Name      Token
+	Kind      DeclarationKind
+	Signature *Signature
+	Global    bool
+	used      bool
+}
+
+// immutable reports whether the name can't be assigned to. Classes are
+// constants too.
+func (d *Declaration) immutable() bool {
+	return d.Kind == CONSTANT_DECLARATION || d.Kind == CLASS_DECLARATION
+}
+
+func variableKind(constant bool) DeclarationKind {
+	if constant {
+		return CONSTANT_DECLARATION
+	}
+	return VARIABLE_DECLARATION
+}
+
+type LintWarning struct {
+	token   Token
+	message string
+}
+
+func (w *LintWarning) Error() string {
+	return fmt.Sprintf("Warning at '%s': %s", w.token.Lexeme, w.message)
+}
+
+// lint resolves the statements like a normal run while also collecting
+// warnings. Top-level declarations are gathered up front so that globals
+// used before their declaration are still known.
+func (r *Resolver) lint(statements []*Stmt) ([]*LintWarning, error) {
+	for name, value := range r.interpreter.globals.snapshot() {
+		var signature *Signature
+		if callable, ok := value.(GloxCallable); ok {
+			native := callable.Signature()
+			signature = &native
+		}
+		r.globals[name] = &Declaration{Name: Token{Lexeme: name}, Kind: FUNCTION_DECLARATION, Signature: signature, Global: true, used: true}
+	}
+	for _, stmt := range statements {
+		switch s := (*stmt).(type) {
+		case StmtVarDeclaration:
+			r.trackGlobal(s.Name, variableKind(s.Const), nil)
+		case StmtDestructure:
+			for _, target := range s.Target.Targets {
+				r.trackGlobal(target.Name, variableKind(s.Const), nil)
+			}
+		case StmtFunction:
+			r.trackGlobal(s.Name, FUNCTION_DECLARATION, declaredSignature(s))
+		case StmtClass:
+			r.trackGlobal(s.Name, CLASS_DECLARATION, classSignature(s))
+		}
+	}
+	err := r.resolveStatements(statements)
+	sort.SliceStable(r.warnings, func(a, b int) bool {
+		ta, tb := r.warnings[a].token, r.warnings[b].token
+		if ta.Line != tb.Line {
+			return ta.Line < tb.Line
+		}
+		return ta.Column < tb.Column
+	})
+	return r.warnings, err
+}
+
+func declaredSignature(stmt StmtFunction) *Signature {
+	signature := functionSignature(stmt)
+	return &signature
+}
+
+func classSignature(stmt StmtClass) *Signature {
+	for _, method := range stmt.Methods {
+		if function := method.(StmtFunction); function.Name.Lexeme == "init" {
+			signature := functionSignature(function)
+			signature.Name = stmt.Name.Lexeme
+			return &signature
+		}
+	}
+	if stmt.Superclass != nil {
+		// the initializer may be inherited, which only the runtime knows
+		return nil
+	}
+	signature := fixedSignature(stmt.Name.Lexeme, 0)
+	return &signature
+}
+
+func (r *Resolver) warn(token Token, message string) {
+	r.warnings = append(r.warnings, &LintWarning{token: token, message: message})
+}
+
+// track records a declaration in the innermost scope, or as a global when
+// there is none, warning when it hides a declaration from an outer scope.
+func (r *Resolver) track(name Token, kind DeclarationKind, signature *Signature) {
+	if r.declarations.IsEmpty() {
+		if _, ok := r.references[name]; !ok {
+			r.trackGlobal(name, kind, signature)
+		}
+		return
+	}
+	declaration := &Declaration{Name: name, Kind: kind, Signature: signature}
+	top := r.declarations.Size() - 1
+	if outer := r.lookupFrom(top-1, name); outer != nil && outer.Name.Line > 0 {
+		r.warn(name, fmt.Sprintf("'%s' shadows the %s declared on line %d.", name.Lexeme, outer.Kind, outer.Name.Line))
+	}
+	r.declarations.elements[top][name.Lexeme] = declaration
+	r.symbols = append(r.symbols, declaration)
+	r.references[name] = declaration
+}
+
+func (r *Resolver) trackGlobal(name Token, kind DeclarationKind, signature *Signature) {
+	declaration := &Declaration{Name: name, Kind: kind, Signature: signature, Global: true}
+	r.globals[name.Lexeme] = declaration
+	r.symbols = append(r.symbols, declaration)
+	r.references[name] = declaration
+}
+
+// trackMethod records a method for symbol queries. Methods are looked up on
+// instances at runtime, so they never enter a scope.
+func (r *Resolver) trackMethod(method StmtFunction) {
+	declaration := &Declaration{Name: method.Name, Kind: METHOD_DECLARATION, Signature: declaredSignature(method), used: true}
+	r.symbols = append(r.symbols, declaration)
+	r.references[method.Name] = declaration
+}
+
+func (r *Resolver) lookup(name Token) *Declaration {
+	return r.lookupFrom(r.declarations.Size()-1, name)
+}
+
+// lookupFrom searches the scopes from index outwards, then the globals.
+func (r *Resolver) lookupFrom(index int, name Token) *Declaration {
+	for i := index; i >= 0; i-- {
+		if declaration, ok := r.declarations.elements[i][name.Lexeme]; ok {
+			return declaration
+		}
+	}
+	return r.globals[name.Lexeme]
+}
+
+// reference links a use of name to the declaration it resolves to. Reads
+// mark the declaration as used, assignments do not.
+func (r *Resolver) reference(name Token, read bool) *Declaration {
+	declaration := r.lookup(name)
+	if declaration != nil {
+		r.references[name] = declaration
+		if read {
+			declaration.used = true
+		}
+	}
+	return declaration
+}
+
+func (r *Resolver) checkUnused(scope map[string]*Declaration) {
+	for _, declaration := range scope {
+		if declaration.used {
+			continue
+		}
+		if declaration.Kind == PARAMETER_DECLARATION {
+			r.warn(declaration.Name, fmt.Sprintf("Unused parameter '%s'.", declaration.Name.Lexeme))
+		} else {
+			r.warn(declaration.Name, fmt.Sprintf("Unused local %s '%s'.", declaration.Kind, declaration.Name.Lexeme))
+		}
+	}
+}
+
+func (r *Resolver) checkUnreachable(statements []*Stmt) {
+	for index, stmt := range statements {
+		if ret, ok := (*stmt).(StmtReturn); ok && index < len(statements)-1 {
+			r.warn(ret.Keyword, "Unreachable code after 'return'.")
+			return
+		}
+	}
+}
+
+func (r *Resolver) checkArity(expr ExprCall) {
+	variable, ok := (*expr.Callee).(ExprVariable)
+	if !ok {
+		return
+	}
+	declaration := r.lookup(variable.Name)
+	if declaration == nil || declaration.Signature == nil {
+		return
+	}
+	signature := *declaration.Signature
+	if len(expr.Names) > 0 {
+		// binding placeholders finds unknown, repeated and missing names
+		if _, err := signature.bind(expr.Paren, expr.Names, make([]interface{}, len(expr.Arguments))); err != nil {
+			r.warn(expr.Paren, err.(*RuntimeError).message)
+		}
+	} else if !signature.accepts(len(expr.Arguments)) {
+		r.warn(expr.Paren, fmt.Sprintf("'%s' expects %s arguments but is called with %d.",
+			variable.Name.Lexeme, signature.arity(), len(expr.Arguments)))
+	}
+}
+
+// checkAssignTarget links an assignment to the declaration it changes,
+// which must not be immutable.
+func (r *Resolver) checkAssignTarget(name Token) error {
+	declaration := r.reference(name, false)
+	if declaration == nil {
+		r.warn(name, fmt.Sprintf("Assignment to undeclared global '%s'.", name.Lexeme))
+		return nil
+	}
+	if declaration.immutable() {
+		return &ResolverError{name, fmt.Sprintf("Can't assign to %s '%s'.", declaration.Kind, name.Lexeme)}
+	}
+	return nil
+}
+
+// checkRedeclaration stops a global declaration from replacing an
+// immutable global, which the runtime would allow.
+func (r *Resolver) checkRedeclaration(name Token) error {
+	if !r.scopes.IsEmpty() {
+		return nil
+	}
+	if existing := r.globals[name.Lexeme]; existing != nil && existing.Name != name && existing.immutable() {
+		return &ResolverError{name, fmt.Sprintf("Can't redeclare %s '%s'.", existing.Kind, name.Lexeme)}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintSource(t *testing.T, source string) ([]string, error) {
+	t.Helper()
+	resolver := NewResolver(NewInterpreter())
+	warnings, err := resolver.lint(parseSource(t, source))
+	messages := make([]string, len(warnings))
+	for index, warning := range warnings {
+		messages[index] = warning.Error()
+	}
+	return messages, err
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		warnings []string
+	}{
+		{"clean", "var a = 1; fun f(x) { return x + a; } print f(2);", nil},
+		{"unused local", "fun f() { var a = 1; } f();",
+			[]string{"Warning at 'a': Unused local variable 'a'."}},
+		{"unused parameter", "fun f(x) { return 1; } f(1);",
+			[]string{"Warning at 'x': Unused parameter 'x'."}},
+		{"assigned but never read", "fun f() { var a; a = 1; } f();",
+			[]string{"Warning at 'a': Unused local variable 'a'."}},
+		{"shadowing", "fun f(a) { { var a = 2; print a; } return a; } f(1);",
+			[]string{"Warning at 'a': 'a' shadows the parameter declared on line 1."}},
+		{"unreachable", "fun f() { return 1; print 2; } f();",
+			[]string{"Warning at 'return': Unreachable code after 'return'."}},
+		{"undeclared global", "fun f() { b = 1; } f();",
+			[]string{"Warning at 'b': Assignment to undeclared global 'b'."}},
+		{"global declared later", "fun f() { return b; } var b = 1; print f();", nil},
+		{"arity", "fun f(a, b) { return a + b; } f(1);",
+			[]string{"Warning at ')': 'f' expects 2 arguments but is called with 1."}},
+		{"native arity", "clock(1);",
+			[]string{"Warning at ')': 'clock' expects 0 arguments but is called with 1."}},
+		{"class arity", "class A { init(x) { this.x = x; } } A();",
+			[]string{"Warning at ')': 'A' expects 1 arguments but is called with 0."}},
+		{"sorted by line", "fun f() {\n  var b;\n}\nfun g(x) {\n  return 1;\n}\nf(); g(1);",
+			[]string{"Warning at 'b': Unused local variable 'b'.", "Warning at 'x': Unused parameter 'x'."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings, err := lintSource(t, test.source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(test.warnings, "\n") {
+				t.Fatalf("expected warnings\n%s\ngot\n%s", strings.Join(test.warnings, "\n"), strings.Join(warnings, "\n"))
+			}
+		})
+	}
+}
+
+func TestLintResolverErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{"return at top level", "return 1;", "Can't return from top-level code."},
+		{"read in own initializer", "{ var a = a; }", "Can't read local variable in its own initializer."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := lintSource(t, test.source)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
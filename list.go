@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GloxList is a growable list of values, such as the arguments a rest
+// parameter collects. Its length is a property; get, set and push are
+// methods. The lock keeps it whole when spawned calls share it.
+type GloxList struct {
+	elements []interface{}
+	lock     sync.Mutex
+}
+
+func NewGloxList(elements []interface{}) *GloxList {
+	return &GloxList{elements: elements}
+}
+
+// values copies the elements.
+func (l *GloxList) values() []interface{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]interface{}{}, l.elements...)
+}
+
+// at returns the element at index, or false past the end.
+func (l *GloxList) at(index int) (interface{}, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if index >= len(l.elements) {
+		return nil, false
+	}
+	return l.elements[index], true
+}
+
+func (l *GloxList) String() string {
+	return l.format(map[*GloxList]bool{})
+}
+
+// format prints l, showing a list that is reached again while it's being
+// printed, because it contains itself, as [...].
+func (l *GloxList) format(printing map[*GloxList]bool) string {
+	if printing[l] {
+		return "[...]"
+	}
+	printing[l] = true
+	defer delete(printing, l)
+	elements := l.values()
+	parts := make([]string, len(elements))
+	for index, element := range elements {
+		if list, ok := element.(*GloxList); ok {
+			parts[index] = list.format(printing)
+		} else {
+			parts[index] = stringify(element)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func (l *GloxList) Get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "length":
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		return int64(len(l.elements)), nil
+	case "get":
+		return &nativeMethod{name: name, signature: fixedSignature("get", 1), fn: func(_ *Interpreter, arguments []interface{}) (interface{}, error) {
+			return l.get(name, arguments[0])
+		}}, nil
+	case "set":
+		return &nativeMethod{name: name, signature: fixedSignature("set", 2), fn: func(_ *Interpreter, arguments []interface{}) (interface{}, error) {
+			return arguments[1], l.set(name, arguments[0], arguments[1])
+		}}, nil
+	case "push":
+		return &nativeMethod{name: name, signature: fixedSignature("push", 1), fn: func(_ *Interpreter, arguments []interface{}) (interface{}, error) {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			l.elements = append(l.elements, arguments[0])
+			return int64(len(l.elements)), nil
+		}}, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+// get returns the element at index, which list[index] reads too.
+func (l *GloxList) get(token Token, index interface{}) (interface{}, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	at, err := l.index(token, index)
+	if err != nil {
+		return nil, err
+	}
+	return l.elements[at], nil
+}
+
+func (l *GloxList) set(token Token, index interface{}, value interface{}) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	at, err := l.index(token, index)
+	if err != nil {
+		return err
+	}
+	l.elements[at] = value
+	return nil
+}
+
+// index checks that value is an integer indexing an element; negative
+// indexes count from the end. The caller holds the lock.
+func (l *GloxList) index(token Token, value interface{}) (int, error) {
+	index, ok := value.(int64)
+	if !ok {
+		return 0, &RuntimeError{token: token, message: fmt.Sprintf("List index must be an integer, but got %s.", integerTypeName(value))}
+	}
+	if index < 0 {
+		index += int64(len(l.elements))
+	}
+	if index < 0 || index >= int64(len(l.elements)) {
+		return 0, &RuntimeError{token: token, message: fmt.Sprintf("List index %d out of range for length %d.", value, len(l.elements))}
+	}
+	return int(index), nil
+}
+
+// nativeMethod is a method of a built-in value, such as a list, bound to
+// it.
+type nativeMethod struct {
+	name      Token
+	signature Signature
+	fn        func(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+func (m nativeMethod) Signature() Signature {
+	return m.signature
+}
+
+func (m nativeMethod) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return m.fn(interpreter, arguments)
+}
+
+func (m nativeMethod) String() string {
+	return "<native fn " + m.name.Lexeme + ">"
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestListPrinting(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "nested", source: `print [1, [2, [3]], "a", nil];`, output: "[1, [2, [3]], a, <nil>]\n"},
+		{name: "same list twice", source: "var a = [1]; print [a, a];", output: "[[1], [1]]\n"},
+		{name: "contains itself", source: "var l = [1]; l.push(l); print l;", output: "[1, [...]]\n"},
+		{name: "cycle through another list", source: "var a = [1]; var b = [a]; a.push(b); print a; print b;", output: "[1, [[...]]]\n[[1, [...]]]\n"},
+		{name: "interpolated", source: `var l = []; l.push(l); print "l is ${l}";`, output: "l is [[...]]\n"},
+	})
+}
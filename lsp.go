@@ -0,0 +1,669 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// LSP symbol, completion and diagnostic kinds, as numbered by the spec.
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspSymbolClass    = 5
+	lspSymbolMethod   = 6
+	lspSymbolFunction = 12
+	lspSymbolVariable = 13
+	lspSymbolConstant = 14
+
+	lspCompletionMethod   = 2
+	lspCompletionFunction = 3
+	lspCompletionVariable = 6
+	lspCompletionClass    = 7
+	lspCompletionKeyword  = 14
+)
+
+type lspRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type lspResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// lspDocument holds an open file together with what the scanner, parser
+// and resolver found in its latest version.
+type lspDocument struct {
+	uri  string
+	text string
+	// the lines of text, for converting columns to UTF-16
+	lines       []string
+	tokens      []Token
+	statements  []*Stmt
+	resolver    Resolver
+	diagnostics []lspDiagnostic
+}
+
+type LspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*lspDocument
+	shutdown  bool
+}
+
+func NewLspServer(in io.Reader, out io.Writer) *LspServer {
+	return &LspServer{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*lspDocument),
+	}
+}
+
+// serve handles messages until the client sends 'exit' or closes the
+// stream. The returned code is the one the process should exit with.
+func (s *LspServer) serve() int {
+	for {
+		request, err := s.read()
+		if err == io.EOF {
+			return 1
+		}
+		if err != nil {
+			s.reply(nil, nil, &lspResponseError{Code: -32700, Message: err.Error()})
+			continue
+		}
+		if request.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		s.handle(request)
+	}
+}
+
+func (s *LspServer) read() (*lspRequest, error) {
+	body, err := readMessage(s.in)
+	if err != nil {
+		return nil, err
+	}
+	var request lspRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *LspServer) write(message map[string]interface{}) {
+	message["jsonrpc"] = "2.0"
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	writeMessage(s.out, body)
+}
+
+func (s *LspServer) reply(id json.RawMessage, result interface{}, err *lspResponseError) {
+	message := map[string]interface{}{"id": id}
+	if id == nil {
+		message["id"] = nil
+	}
+	if err != nil {
+		message["error"] = err
+	} else {
+		message["result"] = result
+	}
+	s.write(message)
+}
+
+func (s *LspServer) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{"method": method, "params": params})
+}
+
+func (s *LspServer) handle(request *lspRequest) {
+	defer func() {
+		if r := recover(); r != nil && request.ID != nil {
+			s.reply(request.ID, nil, &lspResponseError{Code: -32603, Message: fmt.Sprint(r)})
+		}
+	}()
+	var result interface{}
+	var err error
+	switch request.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "glox"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(request.Params, &params); err == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err = json.Unmarshal(request.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params lspTextDocumentPosition
+		if err = json.Unmarshal(request.Params, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.publishDiagnostics(params.TextDocument.URI, []lspDiagnostic{})
+		}
+	case "textDocument/definition":
+		result, err = s.withDocument(request.Params, (*lspDocument).definition)
+	case "textDocument/references":
+		result, err = s.withDocument(request.Params, (*lspDocument).references)
+	case "textDocument/hover":
+		result, err = s.withDocument(request.Params, (*lspDocument).hover)
+	case "textDocument/documentSymbol":
+		result, err = s.withDocument(request.Params, func(d *lspDocument, _ lspPosition) interface{} {
+			return d.symbols()
+		})
+	case "textDocument/completion":
+		result, err = s.withDocument(request.Params, (*lspDocument).completion)
+	default:
+		if request.ID != nil {
+			s.reply(request.ID, nil, &lspResponseError{Code: -32601, Message: "Method not found: " + request.Method})
+		}
+		return
+	}
+	if request.ID == nil {
+		return
+	}
+	if err != nil {
+		s.reply(request.ID, nil, &lspResponseError{Code: -32602, Message: err.Error()})
+	} else {
+		s.reply(request.ID, result, nil)
+	}
+}
+
+func (s *LspServer) withDocument(raw json.RawMessage, query func(*lspDocument, lspPosition) interface{}) (interface{}, error) {
+	var params lspTextDocumentPosition
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	document, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", params.TextDocument.URI)
+	}
+	return query(document, params.Position), nil
+}
+
+func (s *LspServer) update(uri string, text string) {
+	document := analyzeDocument(uri, text)
+	s.documents[uri] = document
+	s.publishDiagnostics(uri, document.diagnostics)
+}
+
+func (s *LspServer) publishDiagnostics(uri string, diagnostics []lspDiagnostic) {
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+func analyzeDocument(uri string, text string) *lspDocument {
+	document := &lspDocument{uri: uri, text: text, lines: strings.Split(text, "\n"), diagnostics: []lspDiagnostic{}}
+	scanner := NewScanner(text)
+	document.tokens = scanner.scanTokens()
+	for _, err := range scanner.errors {
+		scanErr := err.(*ScanError)
+		position := lspPosition{Line: scanErr.line - 1, Character: document.utf16Column(scanErr.line-1, scanErr.column-1)}
+		document.diagnostics = append(document.diagnostics, lspDiagnostic{
+			Range:    lspRange{Start: position, End: lspPosition{Line: position.Line, Character: position.Character + 1}},
+			Severity: lspSeverityError,
+			Source:   "glox",
+			Message:  scanErr.message,
+		})
+	}
+	parser := NewParser(document.tokens)
+	statements, errors := parser.parse()
+	document.statements = statements
+	for _, err := range errors {
+		if parseErr, ok := err.(*ParseError); ok {
+			document.diagnose(parseErr.token, parseErr.message, lspSeverityError)
+		}
+	}
+	document.resolver = NewResolver(NewInterpreter())
+	warnings, err := document.resolver.lint(statements)
+	if resolveErr, ok := err.(*ResolverError); ok {
+		document.diagnose(resolveErr.token, resolveErr.message, lspSeverityError)
+	} else if err == nil {
+		checker := NewChecker(&document.resolver)
+		for _, typeErr := range checker.check(statements) {
+			document.diagnose(typeErr.token, typeErr.message, lspSeverityError)
+		}
+	}
+	for _, warning := range warnings {
+		document.diagnose(warning.token, warning.message, lspSeverityWarning)
+	}
+	return document
+}
+
+func (d *lspDocument) diagnose(token Token, message string, severity int) {
+	d.diagnostics = append(d.diagnostics, lspDiagnostic{
+		Range:    d.tokenRange(token),
+		Severity: severity,
+		Source:   "glox",
+		Message:  message,
+	})
+}
+
+func (d *lspDocument) tokenRange(token Token) lspRange {
+	start := lspPosition{Line: token.Line - 1, Character: d.utf16Column(token.Line-1, token.Column-1)}
+	width := utf16Length(token.Lexeme)
+	if width == 0 {
+		width = 1
+	}
+	return lspRange{Start: start, End: lspPosition{Line: start.Line, Character: start.Character + width}}
+}
+
+func (d *lspDocument) location(token Token) lspLocation {
+	return lspLocation{URI: d.uri, Range: d.tokenRange(token)}
+}
+
+// LSP positions count UTF-16 code units where tokens count runes.
+// utf16Column converts a rune column on line, both counted from 0, and
+// runeColumn converts back.
+func (d *lspDocument) utf16Column(line int, column int) int {
+	if line < 0 || line >= len(d.lines) {
+		return column
+	}
+	units := 0
+	for _, r := range d.lines[line] {
+		if column == 0 {
+			break
+		}
+		units += utf16Length(string(r))
+		column--
+	}
+	return units + column
+}
+
+func (d *lspDocument) runeColumn(position lspPosition) int {
+	if position.Line < 0 || position.Line >= len(d.lines) {
+		return position.Character
+	}
+	runes, units := 0, 0
+	for _, r := range d.lines[position.Line] {
+		width := utf16Length(string(r))
+		if units+width > position.Character {
+			return runes
+		}
+		units += width
+		runes++
+	}
+	return runes + position.Character - units
+}
+
+func utf16Length(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+// tokenAt returns the index of the token under the cursor, or -1. A cursor
+// just past the end of a token still counts as on it.
+func (d *lspDocument) tokenAt(position lspPosition) int {
+	found := -1
+	character := d.runeColumn(position)
+	for index, token := range d.tokens {
+		if token.Line-1 != position.Line || token.TokenType == EOF {
+			continue
+		}
+		start := token.Column - 1
+		width := utf8.RuneCountInString(token.Lexeme)
+		if character >= start && character < start+width {
+			return index
+		}
+		if character == start+width && found < 0 {
+			found = index
+		}
+	}
+	return found
+}
+
+func (d *lspDocument) declarationAt(position lspPosition) *Declaration {
+	index := d.tokenAt(position)
+	if index < 0 {
+		return nil
+	}
+	return d.resolver.references[d.tokens[index]]
+}
+
+func (d *lspDocument) definition(position lspPosition) interface{} {
+	declaration := d.declarationAt(position)
+	if declaration == nil || declaration.Name.Line == 0 {
+		return nil
+	}
+	return d.location(declaration.Name)
+}
+
+func (d *lspDocument) references(position lspPosition) interface{} {
+	locations := []lspLocation{}
+	declaration := d.declarationAt(position)
+	if declaration == nil {
+		return locations
+	}
+	var tokens []Token
+	for token, target := range d.resolver.references {
+		if target == declaration {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(a, b int) bool {
+		if tokens[a].Line != tokens[b].Line {
+			return tokens[a].Line < tokens[b].Line
+		}
+		return tokens[a].Column < tokens[b].Column
+	})
+	for _, token := range tokens {
+		locations = append(locations, d.location(token))
+	}
+	return locations
+}
+
+func (d *lspDocument) hover(position lspPosition) interface{} {
+	index := d.tokenAt(position)
+	if index < 0 {
+		return nil
+	}
+	token := d.tokens[index]
+	var text string
+	if declaration := d.resolver.references[token]; declaration != nil {
+		text = describeDeclaration(declaration)
+	} else if token.TokenType == THIS {
+		text = "(this) the instance the method was called on"
+	} else if token.TokenType == IDENTIFIER && index > 0 && (d.tokens[index-1].TokenType == DOT || d.tokens[index-1].TokenType == QUESTION_DOT) {
+		text = "(property) " + token.Lexeme
+		for _, symbol := range d.resolver.symbols {
+			if symbol.Kind == METHOD_DECLARATION && symbol.Name.Lexeme == token.Lexeme {
+				text = "(method) " + token.Lexeme
+				break
+			}
+		}
+	} else {
+		return nil
+	}
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": text},
+		"range":    d.tokenRange(token),
+	}
+}
+
+func describeDeclaration(declaration *Declaration) string {
+	scope := "local"
+	if declaration.Global {
+		scope = "global"
+	}
+	if declaration.Kind == METHOD_DECLARATION {
+		scope = "class"
+	}
+	text := fmt.Sprintf("(%s %s) %s", scope, declaration.Kind, declaration.Name.Lexeme)
+	if declaration.Signature != nil {
+		text += " — " + declaration.Signature.String()
+	}
+	if declaration.Name.Line > 0 {
+		text += fmt.Sprintf(", declared on line %d", declaration.Name.Line)
+	}
+	return text
+}
+
+func (d *lspDocument) symbols() []lspDocumentSymbol {
+	return d.statementSymbols(d.statements)
+}
+
+func (d *lspDocument) statementSymbols(statements []*Stmt) []lspDocumentSymbol {
+	symbols := []lspDocumentSymbol{}
+	for _, stmt := range statements {
+		switch s := (*stmt).(type) {
+		case StmtFunction:
+			symbols = append(symbols, d.functionSymbol(s, lspSymbolFunction))
+		case StmtClass:
+			class := lspDocumentSymbol{
+				Name:           s.Name.Lexeme,
+				Kind:           lspSymbolClass,
+				Range:          d.tokenRange(s.Name),
+				SelectionRange: d.tokenRange(s.Name),
+			}
+			if s.Superclass != nil {
+				class.Detail = "< " + s.Superclass.Name.Lexeme
+			}
+			for _, method := range s.Methods {
+				class.Children = append(class.Children, d.functionSymbol(method.(StmtFunction), lspSymbolMethod))
+			}
+			symbols = append(symbols, class)
+		case StmtVarDeclaration:
+			kind := lspSymbolVariable
+			if s.Const {
+				kind = lspSymbolConstant
+			}
+			symbols = append(symbols, lspDocumentSymbol{
+				Name:           s.Name.Lexeme,
+				Kind:           kind,
+				Range:          d.tokenRange(s.Name),
+				SelectionRange: d.tokenRange(s.Name),
+			})
+		case StmtDestructure:
+			kind := lspSymbolVariable
+			if s.Const {
+				kind = lspSymbolConstant
+			}
+			for _, target := range s.Target.Targets {
+				symbols = append(symbols, lspDocumentSymbol{
+					Name:           target.Name.Lexeme,
+					Kind:           kind,
+					Range:          d.tokenRange(target.Name),
+					SelectionRange: d.tokenRange(target.Name),
+				})
+			}
+		}
+	}
+	return symbols
+}
+
+func (d *lspDocument) functionSymbol(function StmtFunction, kind int) lspDocumentSymbol {
+	signature := functionSignature(function)
+	symbol := lspDocumentSymbol{
+		Name:           function.Name.Lexeme,
+		Detail:         strings.TrimPrefix(signature.String(), signature.Name),
+		Kind:           kind,
+		Range:          d.tokenRange(function.Name),
+		SelectionRange: d.tokenRange(function.Name),
+	}
+	for _, stmt := range function.Body {
+		if nested, ok := (*stmt).(StmtFunction); ok {
+			symbol.Children = append(symbol.Children, d.functionSymbol(nested, lspSymbolFunction))
+		}
+	}
+	return symbol
+}
+
+// completion offers the keywords, every global and the locals declared in
+// the blocks enclosing the cursor. Blocks are followed through the braces
+// in the token stream, which also works while the file does not parse.
+func (d *lspDocument) completion(position lspPosition) interface{} {
+	items := []lspCompletionItem{}
+	seen := make(map[string]bool)
+	add := func(label string, kind int, detail string) {
+		if !seen[label] {
+			seen[label] = true
+			items = append(items, lspCompletionItem{Label: label, Kind: kind, Detail: detail})
+		}
+	}
+	scopes := d.localsBefore(position)
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for j := len(scopes[i]) - 1; j >= 0; j-- {
+			add(scopes[i][j].Lexeme, lspCompletionVariable, "local")
+		}
+	}
+	var globals []*Declaration
+	for _, declaration := range d.resolver.globals {
+		globals = append(globals, declaration)
+	}
+	sort.Slice(globals, func(a, b int) bool { return globals[a].Name.Lexeme < globals[b].Name.Lexeme })
+	for _, declaration := range globals {
+		kind := lspCompletionVariable
+		switch declaration.Kind {
+		case FUNCTION_DECLARATION:
+			kind = lspCompletionFunction
+		case CLASS_DECLARATION:
+			kind = lspCompletionClass
+		}
+		add(declaration.Name.Lexeme, kind, "global "+string(declaration.Kind))
+	}
+	for _, symbol := range d.resolver.symbols {
+		if symbol.Kind == METHOD_DECLARATION && symbol.Name.Lexeme != "init" {
+			add(symbol.Name.Lexeme, lspCompletionMethod, "method")
+		}
+	}
+	keywords := []string{"and", "case", "class", "const", "default", "else", "false", "for", "fun", "if",
+		"in", "match", "nil", "or", "print", "return", "select", "spawn", "super", "this", "true", "var", "while", "yield"}
+	for _, keyword := range keywords {
+		add(keyword, lspCompletionKeyword, "keyword")
+	}
+	return items
+}
+
+func (d *lspDocument) localsBefore(position lspPosition) [][]Token {
+	var scopes [][]Token
+	var params []Token
+	// the end of the names of a destructuring declaration, whose braces
+	// don't open a scope
+	skip := 0
+	character := d.runeColumn(position)
+	for index, token := range d.tokens {
+		if token.Line-1 > position.Line || (token.Line-1 == position.Line && token.Column-1 >= character) {
+			break
+		}
+		if index < skip {
+			continue
+		}
+		switch token.TokenType {
+		case LEFT_BRACE:
+			scopes = append(scopes, params)
+			params = nil
+		case RIGHT_BRACE:
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		case LEFT_PAREN:
+			if index > 0 && d.tokens[index-1].TokenType == IDENTIFIER {
+				params = d.parameterList(index)
+			}
+		case VAR, CONST, FUN, CLASS:
+			next := d.tokens[index+1]
+			switch next.TokenType {
+			case IDENTIFIER:
+				if len(scopes) > 0 {
+					scopes[len(scopes)-1] = append(scopes[len(scopes)-1], next)
+				}
+			case LEFT_BRACKET, LEFT_BRACE:
+				for skip = index + 2; skip < len(d.tokens); skip++ {
+					name := d.tokens[skip]
+					if name.TokenType == IDENTIFIER && len(scopes) > 0 {
+						scopes[len(scopes)-1] = append(scopes[len(scopes)-1], name)
+					} else if name.TokenType != IDENTIFIER && name.TokenType != COMMA {
+						break
+					}
+				}
+				// past the closing bracket
+				skip++
+			}
+		}
+	}
+	return scopes
+}
+
+// parameterList returns the identifiers of a declaration's parameter list
+// starting at the '(' at index, or nil when no '{' follows the list.
+func (d *lspDocument) parameterList(index int) []Token {
+	var params []Token
+	for i := index + 1; i < len(d.tokens); i++ {
+		switch d.tokens[i].TokenType {
+		case IDENTIFIER:
+			params = append(params, d.tokens[i])
+		case COMMA:
+		case RIGHT_PAREN:
+			if i+1 < len(d.tokens) && d.tokens[i+1].TokenType == LEFT_BRACE {
+				return params
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+const lspTestURI = "file:///test.glox"
+
+// lspSession frames requests the way an editor does, runs the server over
+// them and collects its replies by id and its notifications by method.
+type lspSession struct {
+	input         bytes.Buffer
+	nextID        int
+	responses     map[int]map[string]interface{}
+	notifications map[string][]map[string]interface{}
+	exitCode      int
+}
+
+func (s *lspSession) send(method string, params interface{}) int {
+	s.nextID++
+	s.write(map[string]interface{}{"jsonrpc": "2.0", "id": s.nextID, "method": method, "params": params})
+	return s.nextID
+}
+
+func (s *lspSession) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *lspSession) write(message map[string]interface{}) {
+	body, _ := json.Marshal(message)
+	writeMessage(&s.input, body)
+}
+
+func (s *lspSession) run(t *testing.T) {
+	t.Helper()
+	var output bytes.Buffer
+	s.exitCode = NewLspServer(&s.input, &output).serve()
+	s.responses = make(map[int]map[string]interface{})
+	s.notifications = make(map[string][]map[string]interface{})
+	reader := bufio.NewReader(&output)
+	for {
+		body, err := readMessage(reader)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("reading reply: %v", err)
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal(body, &message); err != nil {
+			t.Fatalf("decoding %s: %v", body, err)
+		}
+		if id, ok := message["id"].(float64); ok {
+			s.responses[int(id)] = message
+		} else {
+			method := message["method"].(string)
+			s.notifications[method] = append(s.notifications[method], message)
+		}
+	}
+}
+
+func (s *lspSession) result(t *testing.T, id int) interface{} {
+	t.Helper()
+	response, ok := s.responses[id]
+	if !ok {
+		t.Fatalf("no reply to request %d", id)
+	}
+	if err, ok := response["error"]; ok {
+		t.Fatalf("request %d failed: %v", id, err)
+	}
+	return response["result"]
+}
+
+func lspPositionParams(line int, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": lspTestURI},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+// openLsp starts a session that initializes the server and opens text.
+func openLsp(text string) *lspSession {
+	session := &lspSession{}
+	session.send("initialize", map[string]interface{}{})
+	session.notify("initialized", map[string]interface{}{})
+	session.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": lspTestURI, "languageId": "glox", "version": 1, "text": text},
+	})
+	return session
+}
+
+// closeLsp shuts the server down the way an editor does.
+func (s *lspSession) close() {
+	s.send("shutdown", nil)
+	s.notify("exit", nil)
+}
+
+func rangeOf(t *testing.T, value interface{}) [4]int {
+	t.Helper()
+	r := value.(map[string]interface{})
+	start := r["start"].(map[string]interface{})
+	end := r["end"].(map[string]interface{})
+	return [4]int{int(start["line"].(float64)), int(start["character"].(float64)), int(end["line"].(float64)), int(end["character"].(float64))}
+}
+
+const lspTestSource = `var greeting = "hi";
+fun greet(name) {
+  var message = greeting + name;
+  return message;
+}
+print greet("you");
+`
+
+func TestLspSession(t *testing.T) {
+	session := openLsp(lspTestSource)
+	initialize := 1
+	definition := session.send("textDocument/definition", lspPositionParams(5, 7))
+	hover := session.send("textDocument/hover", lspPositionParams(5, 8))
+	completion := session.send("textDocument/completion", lspPositionParams(3, 9))
+	nothing := session.send("textDocument/hover", lspPositionParams(0, 0))
+	session.close()
+	session.run(t)
+
+	if session.exitCode != 0 {
+		t.Errorf("expected exit code 0 after shutdown, got %d", session.exitCode)
+	}
+	capabilities := session.result(t, initialize).(map[string]interface{})["capabilities"].(map[string]interface{})
+	for _, capability := range []string{"definitionProvider", "hoverProvider", "completionProvider"} {
+		if _, ok := capabilities[capability]; !ok {
+			t.Errorf("initialize doesn't advertise %s: %v", capability, capabilities)
+		}
+	}
+
+	diagnostics := session.notifications["textDocument/publishDiagnostics"]
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected one diagnostics notification, got %v", diagnostics)
+	}
+	if found := diagnostics[0]["params"].(map[string]interface{})["diagnostics"].([]interface{}); len(found) != 0 {
+		t.Errorf("expected no diagnostics, got %v", found)
+	}
+
+	location := session.result(t, definition).(map[string]interface{})
+	if location["uri"] != lspTestURI {
+		t.Errorf("definition in %v", location["uri"])
+	}
+	if got := rangeOf(t, location["range"]); got != [4]int{1, 4, 1, 9} {
+		t.Errorf("expected greet declared at 1:4-1:9, got %v", got)
+	}
+
+	contents := session.result(t, hover).(map[string]interface{})["contents"].(map[string]interface{})
+	if value := contents["value"].(string); !strings.Contains(value, "(global function) greet") || !strings.Contains(value, "greet(name)") {
+		t.Errorf("unexpected hover %q", value)
+	}
+
+	labels := make(map[string]string)
+	for _, item := range session.result(t, completion).([]interface{}) {
+		item := item.(map[string]interface{})
+		labels[item["label"].(string)] = item["detail"].(string)
+	}
+	expected := map[string]string{
+		"message":  "local",
+		"name":     "local",
+		"greeting": "global variable",
+		"greet":    "global function",
+		"while":    "keyword",
+	}
+	for label, detail := range expected {
+		if labels[label] != detail {
+			t.Errorf("expected completion %q as %q, got %q", label, detail, labels[label])
+		}
+	}
+
+	if result := session.result(t, nothing); result != nil {
+		t.Errorf("expected no hover on a keyword, got %v", result)
+	}
+}
+
+func TestLspDiagnostics(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		span    [4]int
+	}{
+		{"scan error", "var a = @;", "Unexpected character.", [4]int{0, 8, 0, 9}},
+		{"parse error", "var = 1;", "Expect variable name.", [4]int{0, 4, 0, 5}},
+		{"resolver error", "return 1;", "Can't return from top-level code.", [4]int{0, 0, 0, 6}},
+		{"lint warning", "fun f(x) {}", "Unused parameter 'x'.", [4]int{0, 6, 0, 7}},
+		{"type error", "var a: Number = \"s\";", "'a' must be Number but is String.", [4]int{0, 4, 0, 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := openLsp(test.source)
+			session.close()
+			session.run(t)
+			published := session.notifications["textDocument/publishDiagnostics"]
+			if len(published) != 1 {
+				t.Fatalf("expected one diagnostics notification, got %v", published)
+			}
+			diagnostics := published[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+			if len(diagnostics) == 0 {
+				t.Fatalf("expected a diagnostic")
+			}
+			diagnostic := diagnostics[0].(map[string]interface{})
+			if !strings.Contains(diagnostic["message"].(string), test.message) {
+				t.Errorf("expected %q, got %q", test.message, diagnostic["message"])
+			}
+			if got := rangeOf(t, diagnostic["range"]); got != test.span {
+				t.Errorf("expected range %v, got %v", test.span, got)
+			}
+		})
+	}
+}
+
+func TestLspErrors(t *testing.T) {
+	session := &lspSession{}
+	unknown := session.send("textDocument/hover", lspPositionParams(0, 0))
+	missing := session.send("workspace/frobnicate", nil)
+	session.notify("exit", nil)
+	session.run(t)
+	if session.exitCode != 1 {
+		t.Errorf("expected exit code 1 without shutdown, got %d", session.exitCode)
+	}
+	for id, code := range map[int]float64{unknown: -32602, missing: -32601} {
+		err, ok := session.responses[id]["error"].(map[string]interface{})
+		if !ok || err["code"] != code {
+			t.Errorf("expected error %v for request %d, got %v", code, id, session.responses[id])
+		}
+	}
+}
+
+func TestLspUtf16Positions(t *testing.T) {
+	// 😀 is two UTF-16 code units but one rune, and é one of each
+	session := openLsp("var s = \"😀\"; var name = s;\nprint name; var é = @;")
+	definition := session.send("textDocument/definition", lspPositionParams(1, 7))
+	hover := session.send("textDocument/hover", lspPositionParams(0, 25))
+	session.close()
+	session.run(t)
+
+	location := session.result(t, definition).(map[string]interface{})
+	if got := rangeOf(t, location["range"]); got != [4]int{0, 18, 0, 22} {
+		t.Errorf("expected name declared at 0:18-0:22, got %v", got)
+	}
+	contents := session.result(t, hover).(map[string]interface{})["contents"].(map[string]interface{})
+	if value := contents["value"].(string); !strings.Contains(value, "(global variable) s") {
+		t.Errorf("expected hover on s, got %q", value)
+	}
+	diagnostics := session.notifications["textDocument/publishDiagnostics"][0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diagnostics) == 0 {
+		t.Fatal("expected a diagnostic")
+	}
+	if got := rangeOf(t, diagnostics[0].(map[string]interface{})["range"]); got != [4]int{1, 20, 1, 21} {
+		t.Errorf("expected the scan error at 1:20-1:21, got %v", got)
+	}
+}
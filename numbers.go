@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Numbers are int64 for integer literals and float64 otherwise. Arithmetic
+// on two integers stays integral and fails on overflow, except for '/'
+// which always divides exactly and gives a float. As soon as one operand
+// is a float, the other is converted and the result is a float too. '~/'
+// divides and rounds down, '%' takes the remainder of that division, so
+// its sign follows the divisor.
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(value interface{}) float64 {
+	switch number := value.(type) {
+	case int64:
+		return float64(number)
+	case float64:
+		return number
+	}
+	return math.NaN()
+}
+
+func isZero(value interface{}) bool {
+	return toFloat(value) == 0
+}
+
+func overflow(operator Token) error {
+	return &RuntimeError{token: operator, message: "Integer overflow."}
+}
+
+func (i *Interpreter) arithmetic(operator Token, left interface{}, right interface{}) (interface{}, error) {
+	if err := checkNumberOperands(operator, left, right); err != nil {
+		return nil, err
+	}
+	if (operator.TokenType == SLASH || operator.TokenType == TILDE_SLASH || operator.TokenType == PERCENT) && isZero(right) {
+		return nil, &RuntimeError{token: operator, message: "Division by zero."}
+	}
+	a, leftInt := left.(int64)
+	b, rightInt := right.(int64)
+	if leftInt && rightInt {
+		return integerArithmetic(operator, a, b)
+	}
+	return floatArithmetic(operator, toFloat(left), toFloat(right))
+}
+
+func integerArithmetic(operator Token, a int64, b int64) (interface{}, error) {
+	switch operator.TokenType {
+	case PLUS:
+		result := a + b
+		if (a^result)&(b^result) < 0 {
+			return nil, overflow(operator)
+		}
+		return result, nil
+	case MINUS:
+		result := a - b
+		if (a^b)&(a^result) < 0 {
+			return nil, overflow(operator)
+		}
+		return result, nil
+	case STAR:
+		result := a * b
+		if a != 0 && (result/a != b || (a == -1 && b == math.MinInt64)) {
+			return nil, overflow(operator)
+		}
+		return result, nil
+	case SLASH:
+		return float64(a) / float64(b), nil
+	case TILDE_SLASH:
+		if a == math.MinInt64 && b == -1 {
+			return nil, overflow(operator)
+		}
+		result := a / b
+		if (a%b != 0) && ((a < 0) != (b < 0)) {
+			result--
+		}
+		return result, nil
+	case PERCENT:
+		result := a % b
+		if result != 0 && ((result < 0) != (b < 0)) {
+			result += b
+		}
+		return result, nil
+	}
+	return nil, &RuntimeError{token: operator, message: "Unknown operator."}
+}
+
+func floatArithmetic(operator Token, a float64, b float64) (interface{}, error) {
+	switch operator.TokenType {
+	case PLUS:
+		return a + b, nil
+	case MINUS:
+		return a - b, nil
+	case STAR:
+		return a * b, nil
+	case SLASH:
+		return a / b, nil
+	case TILDE_SLASH:
+		return math.Floor(a / b), nil
+	case PERCENT:
+		result := math.Mod(a, b)
+		if result != 0 && ((result < 0) != (b < 0)) {
+			result += b
+		}
+		return result, nil
+	}
+	return nil, &RuntimeError{token: operator, message: "Unknown operator."}
+}
+
+func compare(operator Token, left interface{}, right interface{}) (bool, error) {
+	if err := checkNumberOperands(operator, left, right); err != nil {
+		return false, err
+	}
+	var order int
+	a, leftInt := left.(int64)
+	b, rightInt := right.(int64)
+	if leftInt && rightInt {
+		order = compareOrdered(a, b)
+	} else {
+		order = compareOrdered(toFloat(left), toFloat(right))
+	}
+	switch operator.TokenType {
+	case GREATER:
+		return order > 0, nil
+	case GREATER_EQUAL:
+		return order >= 0, nil
+	case LESS:
+		return order < 0, nil
+	case LESS_EQUAL:
+		return order <= 0, nil
+	}
+	return false, &RuntimeError{token: operator, message: "Unknown operator."}
+}
+
+func compareOrdered[T int64 | float64](a T, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func negate(operator Token, value interface{}) (interface{}, error) {
+	switch number := value.(type) {
+	case int64:
+		if number == math.MinInt64 {
+			return nil, overflow(operator)
+		}
+		return -number, nil
+	case float64:
+		return -number, nil
+	}
+	return nil, &RuntimeError{token: operator, message: fmt.Sprintf("Operand must be a number, but got %s.", typeName(value))}
+}
+
+// numbersEqual compares numbers by value, so 1 == 1.0.
+func numbersEqual(a interface{}, b interface{}) bool {
+	x, leftInt := a.(int64)
+	y, rightInt := b.(int64)
+	if leftInt && rightInt {
+		return x == y
+	}
+	return toFloat(a) == toFloat(b)
+}
+
+// power raises an integer to a non-negative integer exponent exactly,
+// failing on overflow; any other exponent gives a float.
+func power(operator Token, left interface{}, right interface{}) (interface{}, error) {
+	if err := checkNumberOperands(operator, left, right); err != nil {
+		return nil, err
+	}
+	base, leftInt := left.(int64)
+	exponent, rightInt := right.(int64)
+	if !leftInt || !rightInt || exponent < 0 {
+		return math.Pow(toFloat(left), toFloat(right)), nil
+	}
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			product := result * base
+			if result != 0 && (product/result != base || (result == -1 && base == math.MinInt64)) {
+				return nil, overflow(operator)
+			}
+			result = product
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			square := base * base
+			if base != 0 && (square/base != base || base == math.MinInt64) {
+				return nil, overflow(operator)
+			}
+			base = square
+		}
+	}
+	return result, nil
+}
+
+// bitwise applies &, |, ^, << and >>, which only take integers. Shift
+// counts must be between 0 and 63, and a left shift that loses bits of
+// its operand overflows.
+func bitwise(operator Token, left interface{}, right interface{}) (interface{}, error) {
+	a, leftInt := left.(int64)
+	b, rightInt := right.(int64)
+	if !leftInt || !rightInt {
+		return nil, &RuntimeError{
+			token:   operator,
+			message: fmt.Sprintf("Operands must be integers, but got %s and %s.", integerTypeName(left), integerTypeName(right)),
+		}
+	}
+	switch operator.TokenType {
+	case AMPERSAND:
+		return a & b, nil
+	case PIPE:
+		return a | b, nil
+	case CARET:
+		return a ^ b, nil
+	case LESS_LESS, GREATER_GREATER:
+		if b < 0 {
+			return nil, &RuntimeError{token: operator, message: "Shift count must not be negative."}
+		}
+		if b > 63 {
+			return nil, &RuntimeError{token: operator, message: "Shift count must be less than 64."}
+		}
+		if operator.TokenType == LESS_LESS {
+			shifted := a << uint64(b)
+			if shifted>>uint64(b) != a {
+				return nil, overflow(operator)
+			}
+			return shifted, nil
+		}
+		return a >> uint64(b), nil
+	}
+	return nil, &RuntimeError{token: operator, message: "Unknown operator."}
+}
+
+// integerTypeName is typeName, but tells integers and floats apart.
+func integerTypeName(value interface{}) string {
+	switch value.(type) {
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	}
+	return typeName(value)
+}
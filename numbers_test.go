@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegers(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "integer arithmetic", source: "print 7 + 2; print 7 - 9; print 6 * 7;", output: "9\n-2\n42\n"},
+		{name: "past float precision", source: "print 9007199254740993 + 2;", output: "9007199254740995\n"},
+		{name: "division gives a float", source: "print 7 / 2; print 4 / 2;", output: "3.5\n2\n"},
+		{name: "floor division", source: "print 7 ~/ 2; print -7 ~/ 2; print 7 ~/ -2; print 7.5 ~/ 2;", output: "3\n-4\n-4\n3\n"},
+		{name: "modulo follows the divisor", source: "print 7 % 3; print -7 % 3; print 7 % -3; print 5.5 % 2;", output: "1\n2\n-2\n1.5\n"},
+		{name: "promotion", source: "print 1 + 0.5; print 2 * 1.5; print 3 - 0.25;", output: "1.5\n3\n2.75\n"},
+		{name: "comparison across kinds", source: "print 1 < 1.5; print 2 == 2.0; print 3 >= 3;", output: "true\ntrue\ntrue\n"},
+		{name: "largest integer", source: "print 9223372036854775807;", output: "9223372036854775807\n"},
+		{name: "negative extreme", source: "print -9223372036854775807 - 1;", output: "-9223372036854775808\n"},
+
+		{name: "addition overflow", source: "print 9223372036854775807 + 1;", err: "Error at '+': Integer overflow."},
+		{name: "subtraction overflow", source: "print -9223372036854775807 - 2;", err: "Error at '-': Integer overflow."},
+		{name: "multiplication overflow", source: "print 4611686018427387904 * 2;", err: "Error at '*': Integer overflow."},
+		{name: "floor division overflow", source: "var min = -9223372036854775807 - 1; print min ~/ -1;", err: "Error at '~/': Integer overflow."},
+		{name: "division by zero", source: "print 1 / 0;", err: "Error at '/': Division by zero."},
+		{name: "floor division by zero", source: "print 1 ~/ 0.0;", err: "Error at '~/': Division by zero."},
+		{name: "modulo by zero", source: "print 1 % 0;", err: "Error at '%': Division by zero."},
+		{name: "not a number", source: `print 1 % "2";`, err: "Right operand must be a number, but got string."},
+	})
+}
+
+func TestOperators(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "power", source: "print 2 ** 10; print 2 ** -1; print 2 ** 3 ** 2; print -2 ** 2;", output: "1024\n0.5\n512\n-4\n"},
+		{name: "bitwise", source: "print 6 & 3; print 6 | 3; print 6 ^ 3; print ~5;", output: "2\n7\n5\n-6\n"},
+		{name: "shifts", source: "print 1 << 4; print -16 >> 2; print -1 << 63;", output: "16\n-4\n-9223372036854775808\n"},
+		{name: "precedence", source: "print 1 + 2 << 1; print 1 | 2 == 3; print 2 * 3 ** 2;", output: "6\ntrue\n18\n"},
+		{name: "compound assignment", source: "var a = 1; a += 2; a *= 5; a -= 1; a ~/= 3; print a; a **= 2; a %= 7; a <<= 3; a |= 1; a &= 13; a ^= 4; a >>= 1; print a;",
+			output: "4\n2\n"},
+		{name: "string compound assignment", source: `var s = "a"; s += "b"; print s;`, output: "ab\n"},
+		{name: "increment and decrement", source: "var b = 5; print b++; print b; print ++b; print --b; print b--; print b;",
+			output: "5\n6\n7\n6\n6\n5\n"},
+		{name: "properties", source: "class P {} var p = P(); p.x = 1; p.x += 4; p.x++; print p.x; print --p.x;", output: "6\n5\n"},
+		{name: "subscripts", source: "var l = [1, 2, 3]; l[0] += 10; l[1]++; --l[2]; print l; print l[-1];", output: "[11, 3, 2]\n2\n"},
+		{name: "subscript evaluated once", source: "var l = [1, 2]; var i = 0; l[i++] *= 5; print l; print i;", output: "[5, 2]\n1\n"},
+		{name: "in a closure", source: "fun counter() { var n = 0; fun next() { return ++n; } return next; } var c = counter(); c(); print c();",
+			output: "2\n"},
+
+		{name: "float operand", source: "print 1.5 & 1;", err: "Error at '&': Operands must be integers, but got float and integer."},
+		{name: "complement of a float", source: "print ~1.5;", err: "Error at '~': Operand must be an integer, but got float."},
+		{name: "shift too far", source: "print 1 << 64;", err: "Error at '<<': Shift count must be less than 64."},
+		{name: "right shift too far", source: "print 1 >> 64;", err: "Error at '>>': Shift count must be less than 64."},
+		{name: "negative shift", source: "print 1 << -1;", err: "Error at '<<': Shift count must not be negative."},
+		{name: "shift overflow", source: "print 4611686018427387904 << 1;", err: "Error at '<<': Integer overflow."},
+		{name: "power overflow", source: "print 2 ** 63;", err: "Error at '**': Integer overflow."},
+		{name: "compound on nil", source: "var x = nil; x += 1;", err: "Error at '+=': Operands must be two numbers or two strings, but got nil and number."},
+		{name: "compound overflow", source: "var x = 9223372036854775807; x++;", err: "Error at '++': Integer overflow."},
+		{name: "subscript out of range", source: "var l = [1]; l[3] += 1;", err: "Error at '[': List index 3 out of range for length 1."},
+		{name: "subscript of a number", source: "var n = 1; var i = 0; print n[i];", err: "Can only index lists, but got number."},
+	})
+}
+
+func TestInvalidAssignmentTargets(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"5++;", "Error at '++': Invalid assignment target."},
+		{"var a; (a) += 1;", "Error at '+=': Invalid assignment target."},
+		{"fun f() {} f() = 1;", "Error at '=': Invalid assignment target."},
+		{"var a; a + 1 -= 2;", "Error at '-=': Invalid assignment target."},
+	}
+	for _, test := range tests {
+		errors := parseErrors(test.source)
+		if len(errors) == 0 || !strings.Contains(errors[0], test.err) {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, errors)
+		}
+	}
+}
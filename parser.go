@@ -8,6 +8,8 @@ type Parser struct {
 	tokens  []Token
 	current int
 	glox    *Glox
+	// set once the function being parsed has a yield in its body
+	yields bool
 }
 
 type ParseError struct {
@@ -30,6 +32,7 @@ func (p *Parser) parse() ([]*Stmt, []error) {
 		stmt, err := p.declaration()
 		if err != nil {
 			errors = append(errors, err)
+			p.synchronize()
 		} else {
 			statements = append(statements, &stmt)
 		}
@@ -39,34 +42,18 @@ func (p *Parser) parse() ([]*Stmt, []error) {
 
 func (p *Parser) declaration() (Stmt, error) {
 	if p.match(CLASS) {
-		var value, err = p.classDeclaration()
-		if err != nil {
-			p.synchronize()
-			return nil, nil
-		} else {
-			return value, nil
-		}
+		return p.classDeclaration()
 	}
 	if p.match(FUN) {
-		var value, err = p.function("function")
-		if err != nil {
-			p.synchronize()
-			return nil, nil
-		} else {
-			return value, nil
-		}
+		return p.function("function")
 	}
 	if p.match(VAR) {
-		var value, err = p.varDeclaration()
-		if err != nil {
-			p.synchronize()
-			return nil, nil
-		} else {
-			return value, nil
-		}
-	} else {
-		return p.statement()
+		return p.varDeclaration()
 	}
+	if p.match(CONST) {
+		return p.constDeclaration()
+	}
+	return p.statement()
 }
 
 func (p *Parser) classDeclaration() (Stmt, error) {
@@ -89,7 +76,16 @@ func (p *Parser) classDeclaration() (Stmt, error) {
 		return nil, err
 	}
 	methods := []Stmt{}
+	var fields []FieldDeclaration
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		if p.check(IDENTIFIER) && p.checkNext(COLON) {
+			field, err := p.fieldDeclaration()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+			continue
+		}
 		function, err := p.function("method")
 		if err != nil {
 			return nil, err
@@ -100,10 +96,10 @@ func (p *Parser) classDeclaration() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(superclass)
 	return StmtClass{
 		Name:    name,
 		Methods: methods,
+		Fields:  fields,
 		Superclass: func() *ExprVariable {
 			if superclass != (ExprVariable{}) {
 				return &superclass
@@ -114,11 +110,48 @@ func (p *Parser) classDeclaration() (Stmt, error) {
 	}, nil
 }
 
+func (p *Parser) fieldDeclaration() (FieldDeclaration, error) {
+	name := p.advance()
+	p.advance()
+	annotation, err := p.typeAnnotation()
+	if err != nil {
+		return FieldDeclaration{}, err
+	}
+	if _, err := p.consume(SEMICOLON, "Expect ';' after field declaration."); err != nil {
+		return FieldDeclaration{}, err
+	}
+	return FieldDeclaration{Name: name, Type: annotation}, nil
+}
+
+// typeAnnotation parses the type after a ':'.
+func (p *Parser) typeAnnotation() (*TypeAnnotation, error) {
+	name, err := p.consume(IDENTIFIER, "Expect type name.")
+	if err != nil {
+		return nil, err
+	}
+	return &TypeAnnotation{Name: name, Optional: p.match(QUESTION)}, nil
+}
+
+// optionalType parses a ': Type' if there is one.
+func (p *Parser) optionalType() (*TypeAnnotation, error) {
+	if !p.match(COLON) {
+		return nil, nil
+	}
+	return p.typeAnnotation()
+}
+
 func (p *Parser) varDeclaration() (Stmt, error) {
+	if p.check(LEFT_BRACKET) || p.check(LEFT_BRACE) {
+		return p.destructuringDeclaration(p.previous())
+	}
 	var token, err = p.consume(IDENTIFIER, "Expect variable name.")
 	if err != nil {
 		return nil, err
 	}
+	annotation, err := p.optionalType()
+	if err != nil {
+		return nil, err
+	}
 	var initializer Expr
 	if p.match(EQUAL) {
 		initializer, err = p.expression()
@@ -129,7 +162,81 @@ func (p *Parser) varDeclaration() (Stmt, error) {
 	if _, err := p.consume(SEMICOLON, "Expect ';' after variable declaration."); err != nil {
 		return nil, err
 	}
-	return StmtVarDeclaration{Name: token, Initializer: &initializer}, nil
+	if initializer == nil {
+		return StmtVarDeclaration{Name: token, Type: annotation}, nil
+	}
+	return StmtVarDeclaration{Name: token, Initializer: &initializer, Type: annotation}, nil
+}
+
+// constDeclaration parses a var declaration whose initializer is
+// required and whose name can't be assigned to.
+func (p *Parser) constDeclaration() (Stmt, error) {
+	keyword := p.previous()
+	stmt, err := p.varDeclaration()
+	if err != nil {
+		return nil, err
+	}
+	if destructure, ok := stmt.(StmtDestructure); ok {
+		destructure.Const = true
+		return destructure, nil
+	}
+	declaration := stmt.(StmtVarDeclaration)
+	if declaration.Initializer == nil {
+		return nil, p.error(keyword, fmt.Sprintf("Constant '%s' must be initialized.", declaration.Name.Lexeme))
+	}
+	declaration.Const = true
+	return declaration, nil
+}
+
+// destructuringDeclaration parses var [a, b] = value; or var {x, y} =
+// value; after the keyword.
+func (p *Parser) destructuringDeclaration(keyword Token) (Stmt, error) {
+	bracket := p.advance()
+	var targets []ExprVariable
+	closing, message := RIGHT_BRACKET, "Expect ']' after names."
+	if bracket.TokenType == LEFT_BRACE {
+		closing, message = RIGHT_BRACE, "Expect '}' after names."
+	}
+	for {
+		name, err := p.consume(IDENTIFIER, "Expect variable name.")
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, ExprVariable{Name: name})
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	if _, err := p.consume(closing, message); err != nil {
+		return nil, err
+	}
+	target, err := p.destructuring(bracket, targets)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(EQUAL, "Expect '=' after destructuring."); err != nil {
+		return nil, err
+	}
+	initializer, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(SEMICOLON, "Expect ';' after variable declaration."); err != nil {
+		return nil, err
+	}
+	return StmtDestructure{Keyword: keyword, Target: target, Initializer: initializer}, nil
+}
+
+// destructuring checks that no name is destructured into twice.
+func (p *Parser) destructuring(bracket Token, targets []ExprVariable) (Destructuring, error) {
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if seen[target.Name.Lexeme] {
+			return Destructuring{}, p.error(target.Name, fmt.Sprintf("Name '%s' is destructured into twice.", target.Name.Lexeme))
+		}
+		seen[target.Name.Lexeme] = true
+	}
+	return Destructuring{Bracket: bracket, Targets: targets}, nil
 }
 
 func (p *Parser) statement() (Stmt, error) {
@@ -148,6 +255,15 @@ func (p *Parser) statement() (Stmt, error) {
 	if p.match(FOR) {
 		return p.forStatement()
 	}
+	if p.match(MATCH) {
+		return p.matchStatement()
+	}
+	if p.match(YIELD) {
+		return p.yieldStatement()
+	}
+	if p.match(SELECT) {
+		return p.selectStatement()
+	}
 	if p.match(LEFT_BRACE) {
 		var value, err = p.block()
 		if err != nil {
@@ -159,6 +275,7 @@ func (p *Parser) statement() (Stmt, error) {
 }
 
 func (p *Parser) forStatement() (Stmt, error) {
+	var keyword = p.previous()
 	var initializer Stmt
 	if _, err := p.consume(LEFT_PAREN, "Expect '(' at start of for loop."); err != nil {
 		return nil, err
@@ -166,6 +283,9 @@ func (p *Parser) forStatement() (Stmt, error) {
 	if p.match(SEMICOLON) {
 		initializer = nil
 	} else if p.match(VAR) {
+		if p.check(IDENTIFIER) && p.checkNext(IN) {
+			return p.forInStatement(keyword)
+		}
 		var _initializer, err = p.varDeclaration()
 		if err != nil {
 			return nil, err
@@ -205,38 +325,222 @@ func (p *Parser) forStatement() (Stmt, error) {
 		return nil, err
 	}
 	if increment != nil {
-    var statements []Stmt
-    statements = append(statements, StmtExpression{Expression: &increment})
-    stmtPointers := make([]*Stmt, len(statements))
-    for i := range statements {
-      stmtPointers[i] = &statements[i]
-    }
+		var statements = []Stmt{body, StmtExpression{Expression: &increment}}
 		body = StmtBlock{
-			Statements: stmtPointers,
+			Statements: []*Stmt{&statements[0], &statements[1]},
 		}
 	}
 	if condition == nil {
 		condition = ExprLiteral{Value: true}
 	}
 	body = StmtWhile{
+		Keyword:   keyword,
 		Condition: condition,
 		Body:      body,
 	}
 	if initializer != nil {
-    var statements []Stmt
-    statements = append([]Stmt{initializer})
-    stmtPointers := make([]*Stmt, len(statements))
-    for i := range statements {
-      stmtPointers[i] = &statements[i]
-    }
+		var statements = []Stmt{initializer, body}
 		body = StmtBlock{
-			Statements: stmtPointers,
+			Statements: []*Stmt{&statements[0], &statements[1]},
 		}
 	}
 	return body, nil
 }
 
+// forInStatement parses the rest of for (var name in iterable) body.
+func (p *Parser) forInStatement(keyword Token) (Stmt, error) {
+	name := p.advance()
+	p.advance()
+	iterable, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(RIGHT_PAREN, "Expect ')' after iterable."); err != nil {
+		return nil, err
+	}
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	return StmtForIn{Keyword: keyword, Name: name, Iterable: iterable, Body: body}, nil
+}
+
+func (p *Parser) matchStatement() (Stmt, error) {
+	keyword := p.previous()
+	if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'match'."); err != nil {
+		return nil, err
+	}
+	subject, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(RIGHT_PAREN, "Expect ')' after match subject."); err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(LEFT_BRACE, "Expect '{' before match cases."); err != nil {
+		return nil, err
+	}
+	var cases []MatchCase
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		if len(cases) > 0 && len(cases[len(cases)-1].Patterns) == 0 {
+			return nil, p.error(p.peek(), "Default must be the last case.")
+		}
+		var matchCase MatchCase
+		if p.match(DEFAULT) {
+			matchCase.Keyword = p.previous()
+		} else if p.match(CASE) {
+			matchCase.Keyword = p.previous()
+			for {
+				pattern, err := p.pattern()
+				if err != nil {
+					return nil, err
+				}
+				matchCase.Patterns = append(matchCase.Patterns, pattern)
+				if !p.match(COMMA) {
+					break
+				}
+			}
+		} else {
+			return nil, p.error(p.peek(), "Expect 'case' or 'default'.")
+		}
+		if _, err := p.consume(ARROW, "Expect '=>' after case patterns."); err != nil {
+			return nil, err
+		}
+		if matchCase.Body, err = p.statement(); err != nil {
+			return nil, err
+		}
+		cases = append(cases, matchCase)
+	}
+	if _, err := p.consume(RIGHT_BRACE, "Expect '}' after match cases."); err != nil {
+		return nil, err
+	}
+	return StmtMatch{Keyword: keyword, Subject: subject, Cases: cases}, nil
+}
+
+func (p *Parser) selectStatement() (Stmt, error) {
+	keyword := p.previous()
+	if _, err := p.consume(LEFT_BRACE, "Expect '{' before select cases."); err != nil {
+		return nil, err
+	}
+	var cases []SelectCase
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		if len(cases) > 0 && cases[len(cases)-1].Channel == nil {
+			return nil, p.error(p.peek(), "Default must be the last case.")
+		}
+		var selectCase SelectCase
+		if p.match(DEFAULT) {
+			selectCase.Keyword = p.previous()
+		} else if p.match(CASE) {
+			selectCase.Keyword = p.previous()
+			if err := p.selectOperation(&selectCase); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, p.error(p.peek(), "Expect 'case' or 'default'.")
+		}
+		if _, err := p.consume(ARROW, "Expect '=>' after select case."); err != nil {
+			return nil, err
+		}
+		body, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		selectCase.Body = body
+		cases = append(cases, selectCase)
+	}
+	if _, err := p.consume(RIGHT_BRACE, "Expect '}' after select cases."); err != nil {
+		return nil, err
+	}
+	return StmtSelect{Keyword: keyword, Cases: cases}, nil
+}
+
+// selectOperation parses the channel operation of a select case, which is
+// written as a call to send or recv.
+func (p *Parser) selectOperation(selectCase *SelectCase) error {
+	if p.match(VAR) {
+		name, err := p.consume(IDENTIFIER, "Expect variable name.")
+		if err != nil {
+			return err
+		}
+		if _, err := p.consume(EQUAL, "Expect '=' after variable name."); err != nil {
+			return err
+		}
+		selectCase.Name = &name
+	}
+	expr, err := p.call()
+	if err != nil {
+		return err
+	}
+	call, ok := expr.(ExprCall)
+	var get ExprGet
+	if ok {
+		get, ok = (*call.Callee).(ExprGet)
+	}
+	switch {
+	case ok && !get.Optional && len(call.Names) == 0 && get.Name.Lexeme == "recv" && len(call.Arguments) == 0:
+	case ok && !get.Optional && len(call.Names) == 0 && get.Name.Lexeme == "send" && len(call.Arguments) == 1 && selectCase.Name == nil:
+		selectCase.Value = *call.Arguments[0]
+	default:
+		return p.error(selectCase.Keyword, "Expect a channel's recv() or send(value) in select case.")
+	}
+	selectCase.Channel = *get.Object
+	selectCase.Operation = get.Name
+	return nil
+}
+
+func (p *Parser) pattern() (Pattern, error) {
+	if p.match(FALSE) {
+		return PatternLiteral{Token: p.previous(), Value: false}, nil
+	}
+	if p.match(TRUE) {
+		return PatternLiteral{Token: p.previous(), Value: true}, nil
+	}
+	if p.match(NIL) {
+		return PatternLiteral{Token: p.previous(), Value: nil}, nil
+	}
+	if p.match(NUMBER, STRING) {
+		return PatternLiteral{Token: p.previous(), Value: p.previous().Literal}, nil
+	}
+	if p.match(MINUS) {
+		minus := p.previous()
+		number, err := p.consume(NUMBER, "Expect number after '-' in pattern.")
+		if err != nil {
+			return nil, err
+		}
+		value, err := negate(minus, number.Literal)
+		if err != nil {
+			return nil, p.error(number, err.(*RuntimeError).message)
+		}
+		return PatternLiteral{Token: number, Value: value}, nil
+	}
+	name, err := p.consume(IDENTIFIER, "Expect pattern.")
+	if err != nil {
+		return nil, err
+	}
+	if !p.match(LEFT_PAREN) {
+		return PatternBinding{Name: name}, nil
+	}
+	pattern := PatternClass{Class: ExprVariable{Name: name}}
+	if !p.check(RIGHT_PAREN) {
+		for {
+			field, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			pattern.Fields = append(pattern.Fields, field)
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(RIGHT_PAREN, "Expect ')' after class pattern fields."); err != nil {
+		return nil, err
+	}
+	return pattern, nil
+}
+
 func (p *Parser) whileStatement() (Stmt, error) {
+	var keyword = p.previous()
 	if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'while'."); err != nil {
 		return nil, err
 	}
@@ -251,10 +555,11 @@ func (p *Parser) whileStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return StmtWhile{Condition: condition, Body: body}, nil
+	return StmtWhile{Keyword: keyword, Condition: condition, Body: body}, nil
 }
 
 func (p *Parser) ifStatement() (Stmt, error) {
+	var keyword = p.previous()
 	if _, err := p.consume(LEFT_PAREN, "Expect '(' after 'if'."); err != nil {
 		return nil, err
 	}
@@ -277,10 +582,11 @@ func (p *Parser) ifStatement() (Stmt, error) {
 		}
 		elseBranch = branch
 	}
-	return StmtIf{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
+	return StmtIf{Keyword: keyword, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, nil
 }
 
 func (p *Parser) printStatement() (Stmt, error) {
+	var keyword = p.previous()
 	var value, err = p.expression()
 	if err != nil {
 		return nil, err
@@ -288,7 +594,7 @@ func (p *Parser) printStatement() (Stmt, error) {
 	if _, err := p.consume(SEMICOLON, "Expect ';' after value."); err != nil {
 		return nil, err
 	}
-	return StmtPrint{Expression: value}, nil
+	return StmtPrint{Keyword: keyword, Expression: value}, nil
 }
 
 func (p *Parser) returnStatement() (Stmt, error) {
@@ -310,6 +616,23 @@ func (p *Parser) returnStatement() (Stmt, error) {
 	}, nil
 }
 
+func (p *Parser) yieldStatement() (Stmt, error) {
+	keyword := p.previous()
+	p.yields = true
+	var value Expr
+	if !p.check(SEMICOLON) {
+		var err error
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.consume(SEMICOLON, "Expect ';' after yield value."); err != nil {
+		return nil, err
+	}
+	return StmtYield{Keyword: keyword, Value: value}, nil
+}
+
 func (p *Parser) expressionStatement() (Stmt, error) {
 	var value, err = p.expression()
 	if err != nil {
@@ -330,16 +653,44 @@ func (p *Parser) function(kind string) (Stmt, error) {
 		return nil, err
 	}
 	var params []Token
+	var paramTypes []*TypeAnnotation
+	var defaults []*Expr
+	rest := false
 	if !p.check(RIGHT_PAREN) {
 		for {
 			if len(params) >= 255 {
-				parseError(p.peek(), "Cant have more than 255 parameters")
+				return nil, p.error(p.peek(), "Cant have more than 255 parameters")
 			}
+			rest = p.match(DOT_DOT_DOT)
 			if identifier, err := p.consume(IDENTIFIER, "Expect parameter name"); err != nil {
 				return nil, err
 			} else {
 				params = append(params, identifier)
 			}
+			if rest {
+				paramTypes = append(paramTypes, nil)
+				defaults = append(defaults, nil)
+				if !p.check(RIGHT_PAREN) {
+					return nil, p.error(p.peek(), "Rest parameter must be the last")
+				}
+				break
+			}
+			annotation, err := p.optionalType()
+			if err != nil {
+				return nil, err
+			}
+			paramTypes = append(paramTypes, annotation)
+			var value *Expr
+			if p.match(EQUAL) {
+				expr, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				value = &expr
+			} else if len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+				return nil, p.error(p.previous(), "Parameters after one with a default need defaults too")
+			}
+			defaults = append(defaults, value)
 			if !p.match(COMMA) {
 				break
 			}
@@ -348,17 +699,30 @@ func (p *Parser) function(kind string) (Stmt, error) {
 	if _, err := p.consume(RIGHT_PAREN, "Expect ')' after params"); err != nil {
 		return nil, err
 	}
+	returnType, err := p.optionalType()
+	if err != nil {
+		return nil, err
+	}
 	if _, err := p.consume(LEFT_BRACE, "Expect '{' before "+kind+" body"); err != nil {
 		return nil, err
 	}
+	enclosing := p.yields
+	p.yields = false
 	body, err := p.block()
+	generator := p.yields
+	p.yields = enclosing
 	if err != nil {
 		return nil, err
 	}
 	return StmtFunction{
-		Name:   name,
-		Params: params,
-		Body:   body,
+		Name:       name,
+		Params:     params,
+		ParamTypes: paramTypes,
+		Defaults:   defaults,
+		Rest:       rest,
+		ReturnType: returnType,
+		Body:       body,
+		Generator:  generator,
 	}, nil
 }
 
@@ -382,25 +746,154 @@ func (p *Parser) expression() (Expr, error) {
 }
 
 func (p *Parser) assignment() (Expr, error) {
-	var expr, err = p.or()
+	var expr, err = p.conditional()
 	if err != nil {
 		return nil, err
 	}
 	if p.match(EQUAL) {
 		var equals = p.previous()
-		var value, _ = p.assignment()
+		var value, err = p.assignment()
+		if err != nil {
+			return nil, err
+		}
 		if variable, ok := expr.(ExprVariable); ok {
 			var name = variable.Name
 			return ExprAssign{Name: name, Value: &value}, nil
 			//TODO Reread this part of the book
-		} else if get, ok := expr.(ExprGet); ok {
+		} else if get, ok := expr.(ExprGet); ok && !optionalChain(get) {
 			return ExprSet{
 				Object: get.Object,
 				Name:   get.Name,
 				Value:  &value,
 			}, nil
+		} else if index, ok := expr.(ExprIndex); ok && !optionalChain(index) {
+			return ExprIndexSet{
+				Object:  index.Object,
+				Bracket: index.Bracket,
+				Index:   index.Index,
+				Value:   &value,
+			}, nil
+		} else if list, ok := expr.(ExprList); ok {
+			return p.destructuringAssignment(list, equals, value)
+		}
+		return nil, p.error(equals, "Invalid assignment target.")
+	}
+	if p.match(compoundAssignments...) {
+		operator := p.previous()
+		value, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		return p.update(expr, operator, value, false)
+	}
+	return expr, nil
+}
+
+// destructuringAssignment turns the list literal on the left of an '='
+// into the variables assigned to.
+func (p *Parser) destructuringAssignment(list ExprList, equals Token, value Expr) (Expr, error) {
+	var targets []ExprVariable
+	for _, element := range list.Elements {
+		variable, ok := (*element).(ExprVariable)
+		if !ok {
+			return nil, p.error(equals, "Invalid assignment target.")
+		}
+		targets = append(targets, variable)
+	}
+	if len(targets) == 0 {
+		return nil, p.error(equals, "Invalid assignment target.")
+	}
+	target, err := p.destructuring(list.Bracket, targets)
+	if err != nil {
+		return nil, err
+	}
+	return ExprDestructure{Target: target, Value: &value}, nil
+}
+
+var compoundAssignments = []TokenType{
+	PLUS_EQUAL, MINUS_EQUAL, STAR_EQUAL, SLASH_EQUAL, PERCENT_EQUAL, TILDE_SLASH_EQUAL,
+	STAR_STAR_EQUAL, AMPERSAND_EQUAL, PIPE_EQUAL, CARET_EQUAL, LESS_LESS_EQUAL, GREATER_GREATER_EQUAL,
+}
+
+// binaryOperators maps compound assignments, increments and decrements to
+// the operator they apply.
+var binaryOperators = map[TokenType]TokenType{
+	PLUS_EQUAL:            PLUS,
+	MINUS_EQUAL:           MINUS,
+	STAR_EQUAL:            STAR,
+	SLASH_EQUAL:           SLASH,
+	PERCENT_EQUAL:         PERCENT,
+	TILDE_SLASH_EQUAL:     TILDE_SLASH,
+	STAR_STAR_EQUAL:       STAR_STAR,
+	AMPERSAND_EQUAL:       AMPERSAND,
+	PIPE_EQUAL:            PIPE,
+	CARET_EQUAL:           CARET,
+	LESS_LESS_EQUAL:       LESS_LESS,
+	GREATER_GREATER_EQUAL: GREATER_GREATER,
+	PLUS_PLUS:             PLUS,
+	MINUS_MINUS:           MINUS,
+}
+
+// update builds the ExprUpdate for a compound assignment, ++ or --. The
+// operator keeps the position of what was written, so errors point at it.
+func (p *Parser) update(target Expr, operator Token, value Expr, postfix bool) (Expr, error) {
+	switch target.(type) {
+	case ExprVariable:
+	case ExprGet, ExprIndex:
+		if optionalChain(target) {
+			return nil, p.error(operator, "Invalid assignment target.")
+		}
+	default:
+		return nil, p.error(operator, "Invalid assignment target.")
+	}
+	operator.TokenType = binaryOperators[operator.TokenType]
+	return ExprUpdate{Target: target, Operator: operator, Value: &value, Postfix: postfix}, nil
+}
+
+// conditional parses cond ? a : b, which nests to the right like
+// a ? b : c ? d : e.
+func (p *Parser) conditional() (Expr, error) {
+	condition, err := p.coalesce()
+	if err != nil {
+		return nil, err
+	}
+	if !p.match(QUESTION) {
+		return condition, nil
+	}
+	question := p.previous()
+	then, err := p.assignment()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(COLON, "Expect ':' after then branch of conditional expression."); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	return ExprConditional{Condition: &condition, Question: question, Then: &then, Else: &otherwise}, nil
+}
+
+// coalesce parses a ?? b, which is a unless that is nil. It is a logical
+// expression so that b is only evaluated when needed.
+func (p *Parser) coalesce() (Expr, error) {
+	var expr, err = p.or()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(QUESTION_QUESTION) {
+		var operator = p.previous()
+		var right, err = p.or()
+		if err != nil {
+			return nil, err
+		}
+		var left = expr
+		expr = ExprLogical{
+			Operator: operator,
+			Right:    &right,
+			Left:     &left,
 		}
-		fmt.Println(p.error(equals, "Invalid assignment target."))
 	}
 	return expr, nil
 }
@@ -416,10 +909,11 @@ func (p *Parser) or() (Expr, error) {
 		if err != nil {
 			return nil, err
 		}
+		var left = expr
 		expr = ExprLogical{
 			Operator: operator,
 			Right:    &right,
-			Left:     &expr,
+			Left:     &left,
 		}
 	}
 	return expr, nil
@@ -436,10 +930,11 @@ func (p *Parser) and() (Expr, error) {
 		if err != nil {
 			return nil, err
 		}
+		var left = expr
 		expr = ExprLogical{
 			Operator: operator,
 			Right:    &right,
-			Left:     &expr,
+			Left:     &left,
 		}
 	}
 	return expr, nil
@@ -463,13 +958,47 @@ func (p *Parser) equality() (Expr, error) {
 }
 
 func (p *Parser) comparison() (Expr, error) {
-	var expr, err = p.term()
+	var expr, err = p.bitwiseOr()
 	if err != nil {
 		return nil, err
 	}
 	for p.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) {
 		var operator Token = p.previous()
-		var right, err = p.term()
+		var right, err = p.bitwiseOr()
+		if err != nil {
+			return nil, err
+		}
+		expr = ExprBinary{Left: expr, Operator: operator, Right: right}
+	}
+	return expr, nil
+}
+
+// The bitwise operators bind tighter than comparisons, so that
+// a & mask == 0 compares the masked value.
+func (p *Parser) bitwiseOr() (Expr, error) {
+	return p.leftAssociative(p.bitwiseXor, PIPE)
+}
+
+func (p *Parser) bitwiseXor() (Expr, error) {
+	return p.leftAssociative(p.bitwiseAnd, CARET)
+}
+
+func (p *Parser) bitwiseAnd() (Expr, error) {
+	return p.leftAssociative(p.shift, AMPERSAND)
+}
+
+func (p *Parser) shift() (Expr, error) {
+	return p.leftAssociative(p.term, LESS_LESS, GREATER_GREATER)
+}
+
+func (p *Parser) leftAssociative(operand func() (Expr, error), operators ...TokenType) (Expr, error) {
+	var expr, err = operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(operators...) {
+		var operator Token = p.previous()
+		var right, err = operand()
 		if err != nil {
 			return nil, err
 		}
@@ -499,24 +1028,75 @@ func (p *Parser) factor() (Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	for p.match(SLASH, STAR) {
+	for p.match(SLASH, STAR, PERCENT, TILDE_SLASH) {
 		var operator Token = p.previous()
-		var right, _ = p.unary()
+		var right, err = p.unary()
+		if err != nil {
+			return nil, err
+		}
 		expr = ExprBinary{Left: expr, Operator: operator, Right: right}
 	}
 	return expr, nil
 }
 
 func (p *Parser) unary() (Expr, error) {
-	if p.match(BANG, MINUS) {
+	if p.match(BANG, MINUS, TILDE) {
 		var operator Token = p.previous()
-		var right, _ = p.unary()
+		var right, err = p.unary()
+		if err != nil {
+			return nil, err
+		}
 		return ExprUnary{Operator: operator, Right: &right}, nil
 	}
+	if p.match(SPAWN) {
+		keyword := p.previous()
+		expr, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		call, ok := expr.(ExprCall)
+		if !ok {
+			return nil, p.error(keyword, "Expect a call after 'spawn'.")
+		}
+		return ExprSpawn{Keyword: keyword, Call: call}, nil
+	}
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		operator := p.previous()
+		target, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return p.update(target, operator, ExprLiteral{Value: int64(1)}, false)
+	}
+	return p.power()
+}
+
+// power is right associative and binds tighter than a unary minus on its
+// left, so -2 ** 2 is -4 and 2 ** -1 is 0.5.
+func (p *Parser) power() (Expr, error) {
+	var expr, err = p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.match(STAR_STAR) {
+		var operator Token = p.previous()
+		var right, err = p.unary()
+		if err != nil {
+			return nil, err
+		}
+		expr = ExprBinary{Left: expr, Operator: operator, Right: right}
+	}
+	return expr, nil
+}
+
+func (p *Parser) postfix() (Expr, error) {
 	var expr, err = p.call()
 	if err != nil {
 		return nil, err
 	}
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		return p.update(expr, p.previous(), ExprLiteral{Value: int64(1)}, true)
+	}
 	return expr, nil
 }
 
@@ -531,15 +1111,29 @@ func (p *Parser) call() (Expr, error) {
 			if err != nil {
 				return nil, err
 			}
-		} else if p.match(DOT) {
+		} else if p.match(DOT, QUESTION_DOT) {
+			optional := p.previous().TokenType == QUESTION_DOT
 			name, err := p.consume(IDENTIFIER, "Expect property name after '.'.")
 			if err != nil {
 				return nil, err
 			}
+			var object = expr
 			expr = ExprGet{
-				Object: &expr,
-				Name:   name,
+				Object:   &object,
+				Name:     name,
+				Optional: optional,
 			}
+		} else if p.match(LEFT_BRACKET) {
+			bracket := p.previous()
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.consume(RIGHT_BRACKET, "Expect ']' after index."); err != nil {
+				return nil, err
+			}
+			var object = expr
+			expr = ExprIndex{Object: &object, Bracket: bracket, Index: &index}
 		} else {
 			break
 		}
@@ -549,15 +1143,22 @@ func (p *Parser) call() (Expr, error) {
 
 func (p *Parser) finishCall(callee Expr) (Expr, error) {
 	var arguments []*Expr
+	var names []Token
 	if !p.check(RIGHT_PAREN) {
 		for {
+			if p.check(IDENTIFIER) && p.checkNext(COLON) {
+				names = append(names, p.advance())
+				p.advance()
+			} else if len(names) > 0 {
+				return nil, p.error(p.peek(), "Expect named argument after named arguments.")
+			}
 			arg, err := p.expression()
 			if err != nil {
 				return nil, err
 			}
 			arguments = append(arguments, &arg)
 			if len(arguments) >= 255 {
-				fmt.Println("Cant have more than 255 arguments")
+				return nil, p.error(p.peek(), "Cant have more than 255 arguments")
 			}
 			if !p.match(COMMA) {
 				break
@@ -569,7 +1170,7 @@ func (p *Parser) finishCall(callee Expr) (Expr, error) {
 		return nil, err
 	}
 
-	return ExprCall{Callee: &callee, Paren: paren, Arguments: arguments}, nil
+	return ExprCall{Callee: &callee, Paren: paren, Arguments: arguments, Names: names}, nil
 }
 
 func (p *Parser) primary() (Expr, error) {
@@ -602,6 +1203,12 @@ func (p *Parser) primary() (Expr, error) {
 	if p.match(NUMBER, STRING) {
 		return ExprLiteral{Value: p.previous().Literal}, nil
 	}
+	if p.match(INTERPOLATION) {
+		return p.interpolation()
+	}
+	if p.match(LEFT_BRACKET) {
+		return p.list()
+	}
 	if p.match(LEFT_PAREN) {
 		var expr, err = p.expression()
 		if err != nil {
@@ -612,18 +1219,67 @@ func (p *Parser) primary() (Expr, error) {
 		}
 		return ExprGrouping{Expression: &expr}, nil
 	}
-	//Golang throws without a return
-	panic(p.error(p.peek(), "Expect expression."))
+	return nil, p.error(p.peek(), "Expect expression.")
+}
+
+// list parses the elements of a list literal after its '['.
+func (p *Parser) list() (Expr, error) {
+	bracket := p.previous()
+	var elements []*Expr
+	if !p.check(RIGHT_BRACKET) {
+		for {
+			element, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, &element)
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(RIGHT_BRACKET, "Expect ']' after list elements."); err != nil {
+		return nil, err
+	}
+	return ExprList{Bracket: bracket, Elements: elements}, nil
+}
+
+// interpolation parses the rest of a string after its first ${, which the
+// scanner split into INTERPOLATION tokens and a final STRING around the
+// tokens of each expression.
+func (p *Parser) interpolation() (Expr, error) {
+	start := p.previous()
+	var text Expr = ExprLiteral{Value: start.Literal}
+	parts := []*Expr{&text}
+	for {
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, &expr)
+		if p.match(INTERPOLATION) {
+			var text Expr = ExprLiteral{Value: p.previous().Literal}
+			parts = append(parts, &text)
+			continue
+		}
+		end, err := p.consume(STRING, "Expect '}' after interpolated expression.")
+		if err != nil {
+			return nil, err
+		}
+		var text Expr = ExprLiteral{Value: end.Literal}
+		parts = append(parts, &text)
+		return ExprInterpolation{Start: start, Parts: parts}, nil
+	}
 }
 
 func (p *Parser) synchronize() {
 	p.advance()
-	for p.isAtEnd() {
+	for !p.isAtEnd() {
 		if p.previous().TokenType == SEMICOLON {
 			return
 		}
 		switch p.peek().TokenType {
-		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
+		case CLASS, FUN, VAR, CONST, FOR, IF, WHILE, PRINT, RETURN, MATCH, SELECT, YIELD:
 			return
 		}
 
@@ -657,6 +1313,13 @@ func (p *Parser) match(types ...TokenType) bool {
 	return false
 }
 
+func (p *Parser) checkNext(t TokenType) bool {
+	if p.isAtEnd() || p.current+1 >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[p.current+1].TokenType == t
+}
+
 func (p *Parser) check(t TokenType) bool {
 	if p.isAtEnd() {
 		return false
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSynchronize(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		errors []string
+	}{
+		{"var", "print 1 + * 2\nvar a = ;", []string{"Error at '*': Expect expression.", "Error at ';': Expect expression."}},
+		{"const", "print 1 + * 2\nconst b = ;", []string{"Error at '*': Expect expression.", "Error at ';': Expect expression."}},
+		{"match", "print 1 + * 2\nmatch (1) { case 1 => print ; }", []string{"Error at '*': Expect expression.", "Error at ';': Expect expression."}},
+		{"select", "print 1 + * 2\nselect { case 1 => print 1; }", []string{"Error at '*': Expect expression.", "Error at 'case': Expect a channel's recv() or send(value) in select case."}},
+		{"yield", "print 1 + * 2\nyield );", []string{"Error at '*': Expect expression.", "Error at ')': Expect expression."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errors := parseErrors(test.source)
+			if len(errors) < len(test.errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.errors), errors)
+			}
+			for index, expected := range test.errors {
+				if !strings.Contains(errors[index], expected) {
+					t.Errorf("expected error %d to be %q, got %q", index+1, expected, errors[index])
+				}
+			}
+		})
+	}
+}
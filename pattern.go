@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Pattern is one alternative of a match case: a PatternLiteral, a
+// PatternBinding or a PatternClass.
+type Pattern interface{}
+
+// PatternLiteral matches values equal to a number, string, boolean or nil.
+type PatternLiteral struct {
+	Token Token
+	Value interface{}
+}
+
+// PatternBinding matches anything and binds it to Name, unless the name is
+// the wildcard _.
+type PatternBinding struct {
+	Name Token
+}
+
+// PatternClass matches instances of Class or its subclasses. Its Fields are
+// matched against the fields named by the parameters of the class's init, so
+// Point(x, y) reads the fields x and y of a Point whose init takes (x, y).
+type PatternClass struct {
+	Class  ExprVariable
+	Fields []Pattern
+}
+
+type MatchCase struct {
+	// the case or default keyword
+	Keyword Token
+	// empty for default
+	Patterns []Pattern
+	Body     Stmt
+}
+
+const WILDCARD = "_"
+
+// patternBindings lists the names a pattern binds, in order.
+func patternBindings(pattern Pattern) []Token {
+	switch p := pattern.(type) {
+	case PatternBinding:
+		if p.Name.Lexeme != WILDCARD {
+			return []Token{p.Name}
+		}
+	case PatternClass:
+		var names []Token
+		for _, field := range p.Fields {
+			names = append(names, patternBindings(field)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// matchPattern reports whether value matches pattern, defining the names it
+// binds in environment.
+func (i *Interpreter) matchPattern(pattern Pattern, value interface{}, environment *Environment) (bool, error) {
+	switch p := pattern.(type) {
+	case PatternLiteral:
+		return i.isEqual(value, p.Value), nil
+	case PatternBinding:
+		if p.Name.Lexeme != WILDCARD {
+			environment.define(p.Name.Lexeme, value)
+		}
+		return true, nil
+	case PatternClass:
+		return i.matchClass(p, value, environment)
+	}
+	return false, nil
+}
+
+func (i *Interpreter) matchClass(pattern PatternClass, value interface{}, environment *Environment) (bool, error) {
+	callee, err := i.evaluate(pattern.Class)
+	if err != nil {
+		return false, err
+	}
+	var class *GloxClass
+	switch c := callee.(type) {
+	case GloxClass:
+		class = &c
+	case *GloxClass:
+		class = c
+	default:
+		return false, &RuntimeError{token: pattern.Class.Name, message: fmt.Sprintf("'%s' is not a class.", pattern.Class.Name.Lexeme)}
+	}
+	var instance *GloxInstance
+	switch v := value.(type) {
+	case GloxInstance:
+		instance = &v
+	case *GloxInstance:
+		instance = v
+	default:
+		return false, nil
+	}
+	if !instance.Klass.isSubclassOf(class) {
+		return false, nil
+	}
+	if len(pattern.Fields) == 0 {
+		return true, nil
+	}
+	var params []Token
+	if initializer := class.FindMethod("init"); initializer != nil {
+		params = initializer.Declaration.Params
+	}
+	if len(params) != len(pattern.Fields) {
+		return false, &RuntimeError{
+			token:   pattern.Class.Name,
+			message: fmt.Sprintf("Expected %d fields for %s but got %d.", len(params), class.Name, len(pattern.Fields)),
+		}
+	}
+	for index, field := range pattern.Fields {
+		name := Token{TokenType: IDENTIFIER, Lexeme: params[index].Lexeme, Line: pattern.Class.Name.Line}
+		fieldValue, err := instance.Get(name)
+		if err != nil {
+			return false, err
+		}
+		if matched, err := i.matchPattern(field, fieldValue, environment); err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isSubclassOf reports whether c is other or inherits from it. Classes are
+// copied by value, so they are told apart by their method tables.
+func (c *GloxClass) isSubclassOf(other *GloxClass) bool {
+	for class := c; class != nil; class = class.Superclass {
+		if reflect.ValueOf(class.Methods).Pointer() == reflect.ValueOf(other.Methods).Pointer() {
+			return true
+		}
+	}
+	return false
+}
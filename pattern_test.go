@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const shapesSource = `class Shape {}
+class Point < Shape { init(x, y) { this.x = x; this.y = y; } }
+class Circle < Shape { init(center) { this.center = center; } }
+fun describe(v) {
+  match (v) {
+    case 0, 1 => print "small";
+    case "hi" => print "greeting";
+    case nil => print "nothing";
+    case Point(0, y) => print "on the y axis at ${y}";
+    case Point(x, _) => print "point at x ${x}";
+    case Circle(Point(a, b)) => print "circle around ${a}, ${b}";
+    case Shape() => print "some shape";
+    case other => print "other ${other}";
+  }
+}
+`
+
+func TestMatch(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "literals", source: shapesSource + `describe(1); describe("hi"); describe(nil);`, output: "small\ngreeting\nnothing\n"},
+		{name: "class fields", source: shapesSource + "describe(Point(0, 5)); describe(Point(3, 4));", output: "on the y axis at 5\npoint at x 3\n"},
+		{name: "nested", source: shapesSource + "describe(Circle(Point(1, 2)));", output: "circle around 1, 2\n"},
+		{name: "subclass", source: shapesSource + "describe(Circle(2)); describe(Shape());", output: "some shape\nsome shape\n"},
+		{name: "binding", source: shapesSource + "describe(true); describe(2.5);", output: "other true\nother 2.5\n"},
+		{name: "no case matches", source: `match (5) { case 1 => print "one"; } print "after";`, output: "after\n"},
+		{name: "default", source: `match (5) { case 1 => print "one"; default => print "default"; }`, output: "default\n"},
+		{name: "alternatives bind the same names", source: shapesSource + "var y = \"outer\";\nmatch (Point(1, 2)) { case Point(1, y), Point(y, 1) => print y; }\nprint y;",
+			output: "2\nouter\n"},
+		{name: "first match wins", source: "match (1) { case 1 => print 1; case _ => print 2; }", output: "1\n"},
+
+		{name: "wrong number of fields", source: shapesSource + "match (Point(1, 2)) { case Point(x) => print x; }", err: "Error at 'Point': Expected 2 fields for Point but got 1."},
+		{name: "not a class", source: "var k = 1; match (1) { case k(1) => print 1; }", err: "Error at 'k': 'k' is not a class."},
+		{name: "different names", source: "match (1) { case x, y => print 1; }", err: "Error at 'case': Every pattern of a case must bind the same names."},
+		{name: "undefined class", source: "match (1) { case Missing(1) => print 1; }", err: "Undefined variable 'Missing'"},
+	})
+}
+
+func TestMatchSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"match 1 {}", "Error at '1': Expect '(' after 'match'."},
+		{"match (1) case 1 => print 1;", "Error at 'case': Expect '{' before match cases."},
+		{"match (1) { default => print 1; case 1 => print 2; }", "Error at 'case': Default must be the last case."},
+		{"match (1) { case => print 1; }", "Error at '=>': Expect pattern."},
+		{"match (1) { case 1 print 1; }", "Error at 'print': Expect '=>' after case patterns."},
+		{"match (1) { print 1; }", "Error at 'print': Expect 'case' or 'default'."},
+	}
+	for _, test := range tests {
+		errors := parseErrors(test.source)
+		if len(errors) == 0 || !strings.Contains(errors[0], test.err) {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, errors)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProfileEntry accumulates the cost of one function, method, native or
+// class constructor.
+type ProfileEntry struct {
+	Name      string
+	Kind      string
+	Line      int
+	Calls     int
+	Inclusive time.Duration
+	Exclusive time.Duration
+	// frames of this entry currently on the stack, so recursion is only
+	// counted once towards inclusive time
+	active int
+}
+
+type profileFrame struct {
+	entry    *ProfileEntry
+	start    time.Time
+	children time.Duration
+}
+
+// Profiler is a Tracer that times calls and counts line hits. The time a
+// frame spends outside its callees is also added to its full call stack,
+// which is what the folded output for flame graphs is made of.
+type Profiler struct {
+	entries  map[string]*ProfileEntry
+	frames   []profileFrame
+	lineHits map[int]int
+	folded   map[string]time.Duration
+	now      func() time.Time
+}
+
+func NewProfiler(interpreter *Interpreter) *Profiler {
+	profiler := &Profiler{
+		entries:  make(map[string]*ProfileEntry),
+		lineHits: make(map[int]int),
+		folded:   make(map[string]time.Duration),
+		now:      time.Now,
+	}
+	profiler.frames = []profileFrame{{entry: profiler.entry("<script>", "script", 0), start: profiler.now()}}
+	profiler.frames[0].entry.Calls = 1
+	profiler.frames[0].entry.active = 1
+	interpreter.tracers = append(interpreter.tracers, profiler)
+	return profiler
+}
+
+func (p *Profiler) entry(name string, kind string, line int) *ProfileEntry {
+	key := fmt.Sprintf("%s %s %d", kind, name, line)
+	entry, ok := p.entries[key]
+	if !ok {
+		entry = &ProfileEntry{Name: name, Kind: kind, Line: line}
+		p.entries[key] = entry
+	}
+	return entry
+}
+
+func (p *Profiler) statement(stmt Stmt) error {
+	if _, ok := stmt.(StmtBlock); ok {
+		return nil
+	}
+	if line := stmtLine(stmt); line > 0 {
+		p.lineHits[line]++
+	}
+	return nil
+}
+
+func (p *Profiler) call(callee GloxCallable, expr ExprCall) error {
+	var entry *ProfileEntry
+	switch c := callee.(type) {
+	case GloxFunction:
+		if instance, ok := c.Closure.getAt(0, "this").(*GloxInstance); ok {
+			entry = p.entry(instance.Klass.Name+"."+c.Declaration.Name.Lexeme, "method", c.Declaration.Name.Line)
+		} else {
+			entry = p.entry(c.Declaration.Name.Lexeme, "function", c.Declaration.Name.Line)
+		}
+	case GloxClass:
+		entry = p.entry(c.Name, "class", 0)
+	default:
+		entry = p.entry(calleeName(callee, expr), "native", 0)
+	}
+	entry.Calls++
+	entry.active++
+	p.frames = append(p.frames, profileFrame{entry: entry, start: p.now()})
+	return nil
+}
+
+func (p *Profiler) ret(callee GloxCallable, expr ExprCall) {
+	p.pop(p.now())
+}
+
+func (p *Profiler) pop(now time.Time) {
+	frame := p.frames[len(p.frames)-1]
+	elapsed := now.Sub(frame.start)
+	exclusive := elapsed - frame.children
+	frame.entry.Exclusive += exclusive
+	frame.entry.active--
+	if frame.entry.active == 0 {
+		frame.entry.Inclusive += elapsed
+	}
+	p.folded[p.stack()] += exclusive
+	p.frames = p.frames[:len(p.frames)-1]
+	if len(p.frames) > 0 {
+		p.frames[len(p.frames)-1].children += elapsed
+	}
+}
+
+func (p *Profiler) stack() string {
+	var names []string
+	for _, frame := range p.frames {
+		names = append(names, frame.entry.Name)
+	}
+	return strings.Join(names, ";")
+}
+
+// finish closes the script frame; call it once the script has returned.
+func (p *Profiler) finish() {
+	now := p.now()
+	for len(p.frames) > 0 {
+		p.pop(now)
+	}
+}
+
+func (p *Profiler) writeReport(out io.Writer) {
+	var entries []*ProfileEntry
+	for _, entry := range p.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Exclusive != entries[b].Exclusive {
+			return entries[a].Exclusive > entries[b].Exclusive
+		}
+		return entries[a].Name < entries[b].Name
+	})
+	fmt.Fprintf(out, "%-24s %-8s %5s %8s %14s %14s\n", "Name", "Kind", "Line", "Calls", "Inclusive", "Exclusive")
+	for _, entry := range entries {
+		line := "-"
+		if entry.Line > 0 {
+			line = fmt.Sprint(entry.Line)
+		}
+		fmt.Fprintf(out, "%-24s %-8s %5s %8d %14s %14s\n",
+			entry.Name, entry.Kind, line, entry.Calls, entry.Inclusive, entry.Exclusive)
+	}
+
+	var lines []int
+	for line := range p.lineHits {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(a, b int) bool {
+		if p.lineHits[lines[a]] != p.lineHits[lines[b]] {
+			return p.lineHits[lines[a]] > p.lineHits[lines[b]]
+		}
+		return lines[a] < lines[b]
+	})
+	fmt.Fprintf(out, "\n%5s %8s\n", "Line", "Hits")
+	for _, line := range lines {
+		fmt.Fprintf(out, "%5d %8d\n", line, p.lineHits[line])
+	}
+}
+
+// writeFolded writes one "frame;frame;frame weight" line per call stack,
+// weighted in microseconds, as read by flamegraph.pl and similar tools.
+func (p *Profiler) writeFolded(out io.Writer) error {
+	var stacks []string
+	for stack := range p.folded {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		if p.folded[stack] < time.Microsecond {
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "%s %d\n", stack, p.folded[stack].Microseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
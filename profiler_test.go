@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const profileTestSource = `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+class A {
+  m() { return fib(3); }
+}
+print A().m();
+clock();
+`
+
+// tickingClock advances by a millisecond each time it is read, so profiles
+// come out the same on every run.
+func tickingClock() func() time.Time {
+	now := time.Unix(0, 0)
+	return func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+}
+
+func TestProfiler(t *testing.T) {
+	var profiler *Profiler
+	output, err := runSource(t, profileTestSource, func(interpreter *Interpreter) {
+		profiler = NewProfiler(interpreter)
+		profiler.now = tickingClock()
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "2\n" {
+		t.Fatalf("unexpected output %q", output)
+	}
+	profiler.finish()
+
+	calls := make(map[string]int)
+	for _, entry := range profiler.entries {
+		calls[entry.Kind+" "+entry.Name] = entry.Calls
+		if entry.Inclusive < entry.Exclusive {
+			t.Errorf("%s: inclusive %s is less than exclusive %s", entry.Name, entry.Inclusive, entry.Exclusive)
+		}
+	}
+	expected := map[string]int{"script <script>": 1, "function fib": 5, "class A": 1, "method A.m": 1, "native clock": 1}
+	if fmt.Sprint(calls) != fmt.Sprint(expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+	fib := profiler.entry("fib", "function", 1)
+	method := profiler.entry("A.m", "method", 6)
+	if fib.Inclusive > method.Inclusive {
+		t.Errorf("recursive fib counted %s inclusive, more than its caller's %s", fib.Inclusive, method.Inclusive)
+	}
+	if profiler.lineHits[2] != 8 || profiler.lineHits[3] != 2 || profiler.lineHits[8] != 1 {
+		t.Errorf("unexpected line hits %v", profiler.lineHits)
+	}
+
+	var report bytes.Buffer
+	profiler.writeReport(&report)
+	if !strings.HasPrefix(report.String(), "Name") || !strings.Contains(report.String(), "A.m") {
+		t.Errorf("unexpected report:\n%s", report.String())
+	}
+	var folded bytes.Buffer
+	if err := profiler.writeFolded(&folded); err != nil {
+		t.Fatal(err)
+	}
+	for _, stack := range []string{"<script>;A ", "<script>;A.m;fib;fib;fib ", "<script>;clock "} {
+		if !strings.Contains("\n"+folded.String(), "\n"+stack) {
+			t.Errorf("expected stack %q in\n%s", stack, folded.String())
+		}
+	}
+}
+
+// rejectingTracer fails calls to one function.
+type rejectingTracer struct {
+	name string
+}
+
+func (r rejectingTracer) statement(stmt Stmt) error { return nil }
+
+func (r rejectingTracer) call(callee GloxCallable, expr ExprCall) error {
+	if name := calleeName(callee, expr); name == r.name {
+		return &RuntimeError{expr.Paren, "Rejected call to '" + name + "'."}
+	}
+	return nil
+}
+
+func (r rejectingTracer) ret(callee GloxCallable, expr ExprCall) {}
+
+func TestTracerRejectsCall(t *testing.T) {
+	var profiler *Profiler
+	_, err := runSource(t, "fun g() {}\nfun f() { g(); }\nf();", func(interpreter *Interpreter) {
+		profiler = NewProfiler(interpreter)
+		profiler.now = tickingClock()
+		interpreter.tracers = append(interpreter.tracers, rejectingTracer{"g"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "Rejected call to 'g'.") {
+		t.Fatalf("expected the tracer's error, got %v", err)
+	}
+	if len(profiler.frames) != 1 {
+		t.Fatalf("expected only the script frame left, got %d frames", len(profiler.frames))
+	}
+	if g := profiler.entry("g", "function", 1); g.active != 0 {
+		t.Errorf("expected g to be off the stack, %d frames still active", g.active)
+	}
+	profiler.finish()
+}
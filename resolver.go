@@ -9,6 +9,11 @@ type Resolver struct {
 	scopes          Stack[map[string]bool]
 	currentFunction FunctionType
 	currentClass    ClassType
+	declarations    Stack[map[string]*Declaration]
+	globals         map[string]*Declaration
+	warnings        []*LintWarning
+	symbols         []*Declaration
+	references      map[Token]*Declaration
 }
 
 type ResolverError struct {
@@ -23,6 +28,7 @@ const (
 	FUNCTION      FunctionType = "FUNCTION"
 	METHOD        FunctionType = "METHOD"
 	INITIALIZER   FunctionType = "INITIALIZER"
+	GENERATOR     FunctionType = "GENERATOR"
 )
 
 type ClassType string
@@ -43,6 +49,9 @@ func NewResolver(interpreter *Interpreter) Resolver {
 		scopes:          Stack[map[string]bool]{},
 		currentFunction: NONE_FUNCTION,
 		currentClass:    NONE_CLASS,
+		declarations:    Stack[map[string]*Declaration]{},
+		globals:         make(map[string]*Declaration),
+		references:      make(map[Token]*Declaration),
 	}
 }
 
@@ -58,9 +67,13 @@ func (r *Resolver) visitStmtBlock(stmt StmtBlock) error {
 
 func (r *Resolver) visitStmtClass(stmt StmtClass) error {
 	var enclosingClass = r.currentClass
-	r.currentClass = "CLASS"
+	r.currentClass = CLASS_RESOLVER
+	if err := r.checkRedeclaration(stmt.Name); err != nil {
+		return err
+	}
 	r.declare(stmt.Name)
 	r.define(stmt.Name)
+	r.track(stmt.Name, CLASS_DECLARATION, classSignature(stmt))
 	if stmt.Superclass != nil && stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
 		return &ResolverError{
 			token:   stmt.Superclass.Name,
@@ -68,8 +81,10 @@ func (r *Resolver) visitStmtClass(stmt StmtClass) error {
 		}
 	}
 	if stmt.Superclass != nil {
-    r.currentClass = "SUBCLASS"
-		r.resolveExpr(stmt.Superclass)
+    r.currentClass = SUBCLASS
+		if _, err := r.resolveExpr(stmt.Superclass); err != nil {
+			return err
+		}
 	}
 	if stmt.Superclass != nil {
 		r.beginScope()
@@ -90,7 +105,10 @@ func (r *Resolver) visitStmtClass(stmt StmtClass) error {
 		if (method.(StmtFunction)).Name.Lexeme == "init" {
 			declaration = INITIALIZER
 		}
-		r.resolveFunction(method.(StmtFunction), declaration)
+		r.trackMethod(method.(StmtFunction))
+		if err := r.resolveFunction(method.(StmtFunction), declaration); err != nil {
+			return err
+		}
 	}
 	r.endScope()
   if stmt.Superclass != nil {
@@ -101,6 +119,9 @@ func (r *Resolver) visitStmtClass(stmt StmtClass) error {
 }
 
 func (r *Resolver) visitStmtVarDeclaration(stmt StmtVarDeclaration) error {
+	if err := r.checkRedeclaration(stmt.Name); err != nil {
+		return err
+	}
 	r.declare(stmt.Name)
 	if stmt.Initializer != nil {
 		_, err := r.resolveExpr(*stmt.Initializer)
@@ -109,21 +130,24 @@ func (r *Resolver) visitStmtVarDeclaration(stmt StmtVarDeclaration) error {
 		}
 	}
 	r.define(stmt.Name)
+	r.track(stmt.Name, variableKind(stmt.Const), nil)
 	return nil
 }
 
 func (r *Resolver) visitVariableExpr(expr ExprVariable) (interface{}, error) {
-	var value, err = r.scopes.Peek()
-	if err != nil {
-		return err, nil
-	}
-	if value[expr.Name.Lexeme] == false {
-		return nil, &ResolverError{
-			expr.Name,
-			"Can't read local variable in its own initializer.",
+	if !r.scopes.IsEmpty() {
+		var value, err = r.scopes.Peek()
+		if err != nil {
+			return nil, err
+		}
+		if defined, ok := value[expr.Name.Lexeme]; ok && !defined {
+			return nil, &ResolverError{
+				expr.Name,
+				"Can't read local variable in its own initializer.",
+			}
 		}
 	}
-  fmt.Printf("Resolver: Memory address: %p\n", expr)
+	r.reference(expr.Name, true)
 	r.resolveLocal(expr, expr.Name)
 	return nil, nil
 }
@@ -139,13 +163,45 @@ func (r *Resolver) visitThisExpr(expr ExprThis) (interface{}, error) {
 	return nil, nil
 }
 
+func (r *Resolver) visitConditionalExpr(expr ExprConditional) (interface{}, error) {
+	for _, part := range []*Expr{expr.Condition, expr.Then, expr.Else} {
+		if _, err := r.resolveExpr(*part); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) visitUpdateExpr(expr ExprUpdate) (interface{}, error) {
+	if _, err := r.resolveExpr(*expr.Value); err != nil {
+		return nil, err
+	}
+	if variable, ok := expr.Target.(ExprVariable); ok {
+		if err := r.checkAssignTarget(variable.Name); err != nil {
+			return nil, err
+		}
+	}
+	// the target is read before it is written
+	_, err := r.resolveExpr(expr.Target)
+	return nil, err
+}
+
+func (r *Resolver) visitInterpolationExpr(expr ExprInterpolation) (interface{}, error) {
+	for _, part := range expr.Parts {
+		if _, err := r.resolveExpr(*part); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
 func (r *Resolver) visitSuperExpr(expr ExprSuper) (interface{}, error) {
-  if r.currentClass == "NONE_CLASS" {
+  if r.currentClass == NONE_CLASS {
 		return nil, &ResolverError{
 			expr.Keyword,
 			"Can't use 'super' outside of a class.",
 		}
-  } else if r.currentClass != "SUBCLASS" {
+  } else if r.currentClass != SUBCLASS {
 		return nil, &ResolverError{
 			expr.Keyword,
 			"Can't use 'super' in a class with no subclass.",
@@ -159,16 +215,82 @@ func (r *Resolver) visitSuperExpr(expr ExprSuper) (interface{}, error) {
 func (r *Resolver) visitAssignExpr(expr ExprAssign) (interface{}, error) {
 	_, err := r.resolveExpr(*expr.Value)
 	if err != nil {
-		return err, nil
+		return nil, err
+	}
+	if err := r.checkAssignTarget(expr.Name); err != nil {
+		return nil, err
 	}
 	err = r.resolveLocal(expr, expr.Name)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	return nil, nil
 }
 
+func (r *Resolver) visitDestructureExpr(expr ExprDestructure) (interface{}, error) {
+	if _, err := r.resolveExpr(*expr.Value); err != nil {
+		return nil, err
+	}
+	for _, target := range expr.Target.Targets {
+		if err := r.checkAssignTarget(target.Name); err != nil {
+			return nil, err
+		}
+		if err := r.resolveLocal(target, target.Name); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) visitListExpr(expr ExprList) (interface{}, error) {
+	for _, element := range expr.Elements {
+		if _, err := r.resolveExpr(*element); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) visitIndexExpr(expr ExprIndex) (interface{}, error) {
+	if _, err := r.resolveExpr(*expr.Object); err != nil {
+		return nil, err
+	}
+	return r.resolveExpr(*expr.Index)
+}
+
+func (r *Resolver) visitIndexSetExpr(expr ExprIndexSet) (interface{}, error) {
+	if _, err := r.resolveExpr(*expr.Value); err != nil {
+		return nil, err
+	}
+	if _, err := r.resolveExpr(*expr.Object); err != nil {
+		return nil, err
+	}
+	return r.resolveExpr(*expr.Index)
+}
+
+func (r *Resolver) visitStmtDestructure(stmt StmtDestructure) error {
+	for _, target := range stmt.Target.Targets {
+		if err := r.checkRedeclaration(target.Name); err != nil {
+			return err
+		}
+		if err := r.declare(target.Name); err != nil {
+			return err
+		}
+	}
+	if _, err := r.resolveExpr(stmt.Initializer); err != nil {
+		return err
+	}
+	for _, target := range stmt.Target.Targets {
+		r.define(target.Name)
+		r.track(target.Name, variableKind(stmt.Const), nil)
+	}
+	return nil
+}
+
 func (r *Resolver) visitStmtFunction(stmt StmtFunction) error {
+	if err := r.checkRedeclaration(stmt.Name); err != nil {
+		return err
+	}
 	err := r.declare(stmt.Name)
 	if err != nil {
 		return err
@@ -177,6 +299,7 @@ func (r *Resolver) visitStmtFunction(stmt StmtFunction) error {
 	if err != nil {
 		return err
 	}
+	r.track(stmt.Name, FUNCTION_DECLARATION, declaredSignature(stmt))
 	err = r.resolveFunction(stmt, FUNCTION)
 	if err != nil {
 		return err
@@ -219,6 +342,79 @@ func (r *Resolver) visitStmtWhile(stmt StmtWhile) error {
 	return nil
 }
 
+func (r *Resolver) visitStmtMatch(stmt StmtMatch) error {
+	if _, err := r.resolveExpr(stmt.Subject); err != nil {
+		return err
+	}
+	for _, matchCase := range stmt.Cases {
+		r.beginScope()
+		if err := r.resolveCase(matchCase); err != nil {
+			return err
+		}
+		r.endScope()
+	}
+	return nil
+}
+
+// resolveCase binds the names of a case's patterns in the current scope.
+// Every alternative must bind the same names, so the body can use them
+// whichever one matched.
+func (r *Resolver) resolveCase(matchCase MatchCase) error {
+	var names []Token
+	for index, pattern := range matchCase.Patterns {
+		if err := r.resolvePattern(pattern); err != nil {
+			return err
+		}
+		bindings := patternBindings(pattern)
+		if index == 0 {
+			names = bindings
+			for _, name := range names {
+				if err := r.declare(name); err != nil {
+					return err
+				}
+				r.define(name)
+				r.track(name, VARIABLE_DECLARATION, nil)
+			}
+		} else if !sameNames(names, bindings) {
+			return &ResolverError{
+				matchCase.Keyword,
+				"Every pattern of a case must bind the same names.",
+			}
+		}
+	}
+	return r.resolveStmt(matchCase.Body)
+}
+
+func (r *Resolver) resolvePattern(pattern Pattern) error {
+	if class, ok := pattern.(PatternClass); ok {
+		if _, err := r.resolveExpr(class.Class); err != nil {
+			return err
+		}
+		for _, field := range class.Fields {
+			if err := r.resolvePattern(field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sameNames(a []Token, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]bool)
+	for _, name := range a {
+		names[name.Lexeme] = true
+	}
+	for _, name := range b {
+		if !names[name.Lexeme] {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *Resolver) visitStmtPrint(stmt StmtPrint) error {
 	_, err := r.resolveExpr(stmt.Expression)
 	if err != nil {
@@ -241,6 +437,12 @@ func (r *Resolver) visitStmtReturn(stmt StmtReturn) error {
 				"Can't return a value from an initializer.",
 			}
 		}
+		if r.currentFunction == GENERATOR {
+			return &ResolverError{
+				stmt.Keyword,
+				"Can't return a value from a generator.",
+			}
+		}
 		var _, err = r.resolveExpr(stmt.Value)
 		if err != nil {
 			return err
@@ -249,14 +451,70 @@ func (r *Resolver) visitStmtReturn(stmt StmtReturn) error {
 	return nil
 }
 
+func (r *Resolver) visitStmtYield(stmt StmtYield) error {
+	switch r.currentFunction {
+	case NONE_FUNCTION:
+		return &ResolverError{stmt.Keyword, "Can't yield from top-level code."}
+	case INITIALIZER:
+		return &ResolverError{stmt.Keyword, "Can't yield from an initializer."}
+	}
+	if stmt.Value != nil {
+		if _, err := r.resolveExpr(stmt.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) visitStmtSelect(stmt StmtSelect) error {
+	for _, selectCase := range stmt.Cases {
+		if selectCase.Channel != nil {
+			if _, err := r.resolveExpr(selectCase.Channel); err != nil {
+				return err
+			}
+		}
+		if selectCase.Value != nil {
+			if _, err := r.resolveExpr(selectCase.Value); err != nil {
+				return err
+			}
+		}
+		r.beginScope()
+		if selectCase.Name != nil {
+			r.declare(*selectCase.Name)
+			r.define(*selectCase.Name)
+			r.track(*selectCase.Name, VARIABLE_DECLARATION, nil)
+		}
+		if err := r.resolveStmt(selectCase.Body); err != nil {
+			return err
+		}
+		r.endScope()
+	}
+	return nil
+}
+
+func (r *Resolver) visitStmtForIn(stmt StmtForIn) error {
+	if _, err := r.resolveExpr(stmt.Iterable); err != nil {
+		return err
+	}
+	r.beginScope()
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	r.track(stmt.Name, VARIABLE_DECLARATION, nil)
+	if err := r.resolveStmt(stmt.Body); err != nil {
+		return err
+	}
+	r.endScope()
+	return nil
+}
+
 func (r *Resolver) visitBinaryExpr(expr ExprBinary) (interface{}, error) {
 	_, err := r.resolveExpr(expr.Left)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	_, err = r.resolveExpr(expr.Right)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	return nil, nil
 }
@@ -273,15 +531,20 @@ func (r *Resolver) visitUnaryExpr(expr ExprUnary) (interface{}, error) {
 	return r.resolveExpr(*expr.Right)
 }
 
+func (r *Resolver) visitSpawnExpr(expr ExprSpawn) (interface{}, error) {
+	return r.resolveExpr(expr.Call)
+}
+
 func (r *Resolver) visitCallExpr(expr ExprCall) (interface{}, error) {
 	_, err := r.resolveExpr(*expr.Callee)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
+	r.checkArity(expr)
 	for _, arg := range expr.Arguments {
 		_, err = r.resolveExpr(*arg)
 		if err != nil {
-			return err, nil
+			return nil, err
 		}
 	}
 	return nil, nil
@@ -290,7 +553,7 @@ func (r *Resolver) visitCallExpr(expr ExprCall) (interface{}, error) {
 func (r *Resolver) visitGetExpr(expr ExprGet) (interface{}, error) {
 	_, err := r.resolveExpr(*expr.Object)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	return nil, nil
 }
@@ -298,11 +561,11 @@ func (r *Resolver) visitGetExpr(expr ExprGet) (interface{}, error) {
 func (r *Resolver) visitLogicalExpr(expr ExprLogical) (interface{}, error) {
 	_, err := r.resolveExpr(*expr.Left)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	_, err = r.resolveExpr(*expr.Right)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	return nil, nil
 }
@@ -310,20 +573,30 @@ func (r *Resolver) visitLogicalExpr(expr ExprLogical) (interface{}, error) {
 func (r *Resolver) visitSetExpr(expr ExprSet) (interface{}, error) {
 	_, err := r.resolveExpr(*expr.Value)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	_, err = r.resolveExpr(*expr.Object)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	return nil, nil
 }
 
 func (r *Resolver) resolveFunction(stmt StmtFunction, _type FunctionType) error {
 	var enclosingFunction = r.currentFunction
+	if stmt.Generator && _type != INITIALIZER {
+		_type = GENERATOR
+	}
 	r.currentFunction = _type
 	r.beginScope()
-	for _, param := range stmt.Params {
+	for index, param := range stmt.Params {
+		// defaults are evaluated on each call and see the parameters
+		// before them
+		if value := stmt.Defaults[index]; value != nil {
+			if _, err := r.resolveExpr(*value); err != nil {
+				return err
+			}
+		}
 		err := r.declare(param)
 		if err != nil {
 			return err
@@ -332,8 +605,11 @@ func (r *Resolver) resolveFunction(stmt StmtFunction, _type FunctionType) error
 		if err != nil {
 			return err
 		}
+		r.track(param, PARAMETER_DECLARATION, nil)
+	}
+	if err := r.resolveStatements(stmt.Body); err != nil {
+		return err
 	}
-	r.resolveStatements(stmt.Body)
 	r.endScope()
 	r.currentFunction = enclosingFunction
 	return nil
@@ -348,6 +624,7 @@ func (r *Resolver) resolveExpr(expr Expr) (interface{}, error) {
 }
 
 func (r *Resolver) resolveStatements(statements []*Stmt) error {
+	r.checkUnreachable(statements)
 	for _, stmt := range statements {
 		err := r.resolveStmt(*stmt)
 		if err != nil {
@@ -401,10 +678,14 @@ func (r *Resolver) define(name Token) error {
 func (r *Resolver) beginScope() error {
 	var scope = make(map[string]bool)
 	r.scopes.Push(scope)
+	r.declarations.Push(make(map[string]*Declaration))
 	return nil
 }
 
 func (r *Resolver) endScope() error {
+	if declarations, err := r.declarations.Pop(); err == nil {
+		r.checkUnused(declarations)
+	}
 	var _, err = r.scopes.Pop()
 	return err
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConst(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "global", source: "const A = 1; print A;", output: "1\n"},
+		{name: "local", source: "fun f() { const B = 2; return B; } print f();", output: "2\n"},
+		{name: "shadowed by a variable", source: "const A = 1; { var A = 2; A = 3; print A; } print A;", output: "3\n1\n"},
+		{name: "contents stay mutable", source: "const l = [1]; l[0] = 2; print l;", output: "[2]\n"},
+		{name: "destructured", source: "const [a, b] = [1, 2]; print a + b;", output: "3\n"},
+
+		{name: "assignment", source: "const A = 1; A = 2;", err: "Error at 'A': Can't assign to constant 'A'."},
+		{name: "compound assignment", source: "const A = 1; A += 2;", err: "Error at 'A': Can't assign to constant 'A'."},
+		{name: "increment", source: "const A = 1; A++;", err: "Error at 'A': Can't assign to constant 'A'."},
+		{name: "in a closure", source: "fun f() { const B = 1; fun g() { B = 2; } }", err: "Error at 'B': Can't assign to constant 'B'."},
+		{name: "destructuring assignment", source: "const [a, b] = [1, 2]; var c; [a, c] = [3, 4];", err: "Error at 'a': Can't assign to constant 'a'."},
+		{name: "destructured const", source: "const [a, b] = [1, 2]; a = 3;", err: "Error at 'a': Can't assign to constant 'a'."},
+		{name: "redeclared", source: "const A = 1; var A = 2;", err: "Error at 'A': Can't redeclare constant 'A'."},
+		{name: "class name", source: "class C {} C = 1;", err: "Error at 'C': Can't assign to class 'C'."},
+	})
+	if messages := parseErrors("const A;"); len(messages) != 1 || messages[0] != "Error at 'const': Constant 'A' must be initialized." {
+		t.Errorf("expected an uninitialized constant error, got %v", messages)
+	}
+}
+
+// TestConstAtRuntime covers globals assigned before the resolver has seen
+// their declaration, which only Environment.assign can catch.
+func TestConstAtRuntime(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "declared after the function", source: "fun g() { H = 2; }\nconst H = 1;\nprint H;\ng();",
+			output: "1\n", err: "Error at 'H': Can't assign to constant 'H'"},
+		{name: "not declared yet", source: "fun g() { H = 2; }\ng();\nconst H = 1;", err: "Error at 'H': Undefined variable 'H'"},
+	})
+	_, err := runSource(t, "fun g() { H = 2; }\nconst H = 1;\ng();")
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) {
+		t.Errorf("expected a runtime error, got %T", err)
+	}
+}
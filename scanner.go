@@ -1,7 +1,11 @@
 package main
 
 import (
+  "fmt"
   "strconv"
+  "strings"
+  "unicode"
+  "unicode/utf8"
 )
 
 type Scanner struct {
@@ -10,6 +14,21 @@ type Scanner struct {
 	start   int
 	current int
 	line    int
+	// offset of the first byte of the current line, used for columns
+	lineStart int
+	errors    []error
+	// open braces inside each ${...} being scanned, innermost last
+	interpolations []int
+}
+
+type ScanError struct {
+	line    int
+	column  int
+	message string
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("[line %d:%d] Error: %s", e.line, e.column, e.message)
 }
 
 func NewScanner(source string) Scanner {
@@ -26,56 +45,290 @@ func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
-func (s *Scanner) match(expected byte) bool {
+func (s *Scanner) match(expected rune) bool {
 	if s.isAtEnd() {
 		return false
 	}
-	if s.source[s.current] != expected {
+	c, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if c != expected {
 		return false
 	}
-	s.current++
+	s.current += size
 	return true
 }
 
-func (s *Scanner) advance() byte {
-	c := s.source[s.current]
-	s.current += 1
+// advance decodes the next rune. A byte that isn't valid UTF-8 is reported
+// where it is and comes back as utf8.RuneError.
+func (s *Scanner) advance() rune {
+	c, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if c == utf8.RuneError && size == 1 {
+		s.errorAt(s.current, fmt.Sprintf("Invalid UTF-8 byte 0x%02x.", s.source[s.current]))
+	}
+	s.current += size
 	return c
 }
 
+// number scans a decimal literal, which may have a fraction and an
+// exponent, or an integer written as 0x, 0b or 0o followed by digits in that
+// base. Digits can be grouped with single underscores between them.
 func (s *Scanner) number() {
-	for s.isDigit(s.peek()) {
-		s.advance()
+	bases := map[rune]int{'x': 16, 'X': 16, 'b': 2, 'B': 2, 'o': 8, 'O': 8}
+	if base, ok := bases[s.peek()]; ok && s.source[s.start] == '0' {
+		prefix := s.advance()
+		digits, ok := s.digits(func(c rune) bool { return digitValue(c) < base })
+		if !ok {
+			return
+		}
+		if digits == "" {
+			s.error(fmt.Sprintf("Expect digits after '0%c'.", prefix))
+			return
+		}
+		if !s.endOfNumber() {
+			return
+		}
+		number, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			s.error("Integer literal is too large.")
+			return
+		}
+		s.addToken(NUMBER, number)
+		return
 	}
+
+	s.current = s.start
+	text, ok := s.digits(s.isDigit)
+	if !ok {
+		return
+	}
+	isFloat := false
 	if s.peek() == '.' && s.isDigit(s.peekNext()) {
 		s.advance()
-		for s.isDigit(s.peek()) {
-			s.advance()
+		fraction, ok := s.digits(s.isDigit)
+		if !ok {
+			return
+		}
+		text += "." + fraction
+		isFloat = true
+	}
+	if s.peek() == 'e' || s.peek() == 'E' {
+		s.advance()
+		text += "e"
+		if s.peek() == '+' || s.peek() == '-' {
+			text += string(s.advance())
+		}
+		exponent, ok := s.digits(s.isDigit)
+		if !ok {
+			return
+		}
+		if exponent == "" {
+			s.errorAt(s.current, "Expect digits in exponent.")
+			return
+		}
+		text += exponent
+		isFloat = true
+	}
+	if !s.endOfNumber() {
+		return
+	}
+	if isFloat {
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			s.error("Number literal is out of range.")
+			return
 		}
+		s.addToken(NUMBER, number)
+		return
+	}
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		s.error("Integer literal is too large.")
+		return
 	}
-  var number, _ = strconv.ParseFloat(s.source[s.start:s.current], 64)
 	s.addToken(NUMBER, number)
 }
 
+// digits consumes a run of digits with optional single underscores between
+// them and returns the digits alone. A misplaced underscore is reported at
+// its position.
+func (s *Scanner) digits(isDigit func(c rune) bool) (string, bool) {
+	var digits strings.Builder
+	for isDigit(s.peek()) || s.peek() == '_' {
+		if s.peek() == '_' {
+			if digits.Len() == 0 || !isDigit(s.peekNext()) {
+				s.errorAt(s.current, "Misplaced '_' in number literal.")
+				s.skipNumber()
+				return "", false
+			}
+			s.advance()
+			continue
+		}
+		digits.WriteRune(s.advance())
+	}
+	return digits.String(), true
+}
+
+// endOfNumber reports letters and digits running on from a literal, such
+// as the G in 0xFG.
+func (s *Scanner) endOfNumber() bool {
+	if !s.isAlphaNumeric(s.peek()) {
+		return true
+	}
+	s.errorAt(s.current, fmt.Sprintf("Unexpected '%c' in number literal.", s.peek()))
+	s.skipNumber()
+	return false
+}
+
+// skipNumber moves past the rest of a malformed literal so that it is only
+// reported once.
+func (s *Scanner) skipNumber() {
+	for s.isAlphaNumeric(s.peek()) || s.peek() == '_' {
+		s.advance()
+	}
+}
+
+func digitValue(c rune) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return 16
+}
+
+// string scans a string literal from just after its opening quote, or its
+// remainder after the '}' closing an interpolated expression. Text followed
+// by ${ becomes an INTERPOLATION token and the expression is scanned as
+// ordinary tokens.
 func (s *Scanner) string() {
+	var value strings.Builder
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
+		c := s.advance()
+		switch c {
+		case '\n':
 			s.line++
+			s.lineStart = s.current
+		case '\\':
+			s.escape(&value)
+			continue
+		case '$':
+			if s.match('{') {
+				s.addToken(INTERPOLATION, value.String())
+				s.interpolations = append(s.interpolations, 0)
+				return
+			}
 		}
-		s.advance()
+		value.WriteRune(c)
 	}
 	if s.isAtEnd() {
-		reportError(s.line, "Unterminated string.")
+		s.error("Unterminated string.")
 		return
 	}
 	s.advance()
-	value := s.source[s.start+1 : s.current-1]
-	s.addToken(STRING, value)
+	s.addToken(STRING, value.String())
+}
+
+var escapes = map[rune]string{
+	'n':  "\n",
+	't':  "\t",
+	'r':  "\r",
+	'0':  "\000",
+	'"':  "\"",
+	'\'': "'",
+	'\\': "\\",
+	'$':  "$",
+}
+
+// escape decodes the escape sequence after a backslash: one of \n \t \r \0
+// \" \' \\ \$, or a code point as \uXXXX or \u{X...}.
+func (s *Scanner) escape(value *strings.Builder) {
+	if s.isAtEnd() {
+		return
+	}
+	backslash := s.current - 1
+	c := s.advance()
+	if decoded, ok := escapes[c]; ok {
+		value.WriteString(decoded)
+		return
+	}
+	if c != 'u' {
+		s.errorAt(backslash, fmt.Sprintf("Invalid escape sequence '\\%c'.", c))
+		return
+	}
+	var digits string
+	if s.match('{') {
+		start := s.current
+		for s.peek() != '}' && s.peek() != '"' && !s.isAtEnd() {
+			s.advance()
+		}
+		digits = s.source[start:s.current]
+		if !s.match('}') {
+			s.errorAt(backslash, "Expect '}' after Unicode escape.")
+			return
+		}
+	} else {
+		start := s.current
+		for i := 0; i < 4 && isHexDigit(s.peek()); i++ {
+			s.advance()
+		}
+		digits = s.source[start:s.current]
+		if len(digits) != 4 {
+			s.errorAt(backslash, "Expect 4 hex digits in Unicode escape.")
+			return
+		}
+	}
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		s.errorAt(backslash, fmt.Sprintf("Invalid Unicode escape '%s'.", s.source[backslash:s.current]))
+		return
+	}
+	value.WriteRune(rune(code))
+}
+
+func isHexDigit(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// blockComment skips a /* */ comment, which may contain other block
+// comments.
+func (s *Scanner) blockComment() {
+	line, column := s.line, s.column()
+	depth := 1
+	for depth > 0 {
+		if s.isAtEnd() {
+			s.errors = append(s.errors, &ScanError{line: line, column: column, message: "Unterminated block comment."})
+			return
+		}
+		c := s.advance()
+		switch {
+		case c == '\n':
+			s.line++
+			s.lineStart = s.current
+		case c == '/' && s.peek() == '*':
+			s.advance()
+			depth++
+		case c == '*' && s.peek() == '/':
+			s.advance()
+			depth--
+		}
+	}
+}
+
+// operator adds an operator token, or its compound assignment form when
+// it is followed by '='.
+func (s *Scanner) operator(plain TokenType, assignment TokenType) {
+	if s.match('=') {
+		s.addToken(assignment, nil)
+	} else {
+		s.addToken(plain, nil)
+	}
 }
 
 func (s *Scanner) addToken(tokenType TokenType, literal interface{}) {
 	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, NewToken(tokenType, text, literal, s.line))
+	s.tokens = append(s.tokens, NewToken(tokenType, text, literal, s.line, s.column()))
 }
 
 func (s *Scanner) scanTokens() []Token {
@@ -83,54 +336,94 @@ func (s *Scanner) scanTokens() []Token {
 		s.start = s.current
 		s.scanToken()
 	}
-	s.tokens = append(s.tokens, NewToken(EOF, "", nil, s.line))
+	s.start = s.current
+	if len(s.interpolations) > 0 {
+		s.error("Unterminated string interpolation.")
+	}
+	s.tokens = append(s.tokens, NewToken(EOF, "", nil, s.line, s.column()))
 	return s.tokens
 }
 
-func (s *Scanner) peek() byte {
+func (s *Scanner) error(message string) {
+	s.errors = append(s.errors, &ScanError{line: s.line, column: s.column(), message: message})
+}
+
+// errorAt reports an error at a byte offset on the current line.
+func (s *Scanner) errorAt(offset int, message string) {
+	s.errors = append(s.errors, &ScanError{line: s.line, column: s.columnAt(offset), message: message})
+}
+
+// column is the position of the current token's first rune on its line,
+// counted in runes from 1.
+func (s *Scanner) column() int {
+	return s.columnAt(s.start)
+}
+
+func (s *Scanner) columnAt(offset int) int {
+	if offset < s.lineStart {
+		return 1
+	}
+	return utf8.RuneCountInString(s.source[s.lineStart:offset]) + 1
+}
+
+func (s *Scanner) peek() rune {
 	if s.isAtEnd() {
 		return '\000'
 	}
-	return s.source[s.current]
+	c, _ := utf8.DecodeRuneInString(s.source[s.current:])
+	return c
 }
 
-func (s *Scanner) peekNext() byte {
-	if s.current+1 >= len(s.source) {
+func (s *Scanner) peekNext() rune {
+	if s.isAtEnd() {
 		return '\000'
 	}
-	return s.source[s.current+1]
+	_, size := utf8.DecodeRuneInString(s.source[s.current:])
+	if s.current+size >= len(s.source) {
+		return '\000'
+	}
+	c, _ := utf8.DecodeRuneInString(s.source[s.current+size:])
+	return c
 }
 
-func (s *Scanner) isDigit(c byte) bool {
+func (s *Scanner) isDigit(c rune) bool {
 	return c >= '0' && c <= '9'
 }
 
-func (s *Scanner) isAlpha(c byte) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+func (s *Scanner) isAlpha(c rune) bool {
+	return unicode.IsLetter(c) || c == '_'
 }
 
-func (s *Scanner) isAlphaNumeric(c byte) bool {
-	return s.isAlpha(c) || s.isDigit(c)
+func (s *Scanner) isAlphaNumeric(c rune) bool {
+	return s.isAlpha(c) || unicode.IsDigit(c) || unicode.In(c, unicode.Mn, unicode.Mc)
 }
 
 func (s *Scanner) identifier() {
 	keywords := map[string]TokenType{
-		"and":    AND,
-		"class":  CLASS,
-		"else":   ELSE,
-		"false":  FALSE,
-		"for":    FOR,
-		"fun":    FUN,
-		"if":     IF,
-		"nil":    NIL,
-		"or":     OR,
-		"print":  PRINT,
-		"return": RETURN,
-		"super":  SUPER,
-		"this":   THIS,
-		"true":   TRUE,
-		"var":    VAR,
-		"while":  WHILE,
+		"and":     AND,
+		"class":   CLASS,
+		"else":    ELSE,
+		"false":   FALSE,
+		"for":     FOR,
+		"fun":     FUN,
+		"if":      IF,
+		"nil":     NIL,
+		"or":      OR,
+		"print":   PRINT,
+		"return":  RETURN,
+		"super":   SUPER,
+		"this":    THIS,
+		"true":    TRUE,
+		"var":     VAR,
+		"const":   CONST,
+		"while":   WHILE,
+		"match":   MATCH,
+		"case":    CASE,
+		"default": DEFAULT,
+		"yield":   YIELD,
+		"in":      IN,
+		"spawn":   SPAWN,
+		"select":  SELECT,
 	}
 	for s.isAlphaNumeric(s.peek()) {
 		s.advance()
@@ -154,21 +447,78 @@ func (s *Scanner) scanToken() {
 	case ')':
 		addToken(RIGHT_PAREN)
 	case '{':
+		if depth := len(s.interpolations); depth > 0 {
+			s.interpolations[depth-1]++
+		}
 		addToken(LEFT_BRACE)
 	case '}':
+		if depth := len(s.interpolations); depth > 0 {
+			if s.interpolations[depth-1] == 0 {
+				s.interpolations = s.interpolations[:depth-1]
+				s.string()
+				return
+			}
+			s.interpolations[depth-1]--
+		}
 		addToken(RIGHT_BRACE)
+	case '[':
+		addToken(LEFT_BRACKET)
+	case ']':
+		addToken(RIGHT_BRACKET)
 	case ',':
 		addToken(COMMA)
 	case '.':
-		addToken(DOT)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			addToken(DOT_DOT_DOT)
+		} else {
+			addToken(DOT)
+		}
 	case '-':
-		addToken(MINUS)
+		if s.match('-') {
+			addToken(MINUS_MINUS)
+		} else {
+			s.operator(MINUS, MINUS_EQUAL)
+		}
 	case '+':
-		addToken(PLUS)
+		if s.match('+') {
+			addToken(PLUS_PLUS)
+		} else {
+			s.operator(PLUS, PLUS_EQUAL)
+		}
 	case ';':
 		addToken(SEMICOLON)
+	case ':':
+		addToken(COLON)
+	case '?':
+		if s.match('?') {
+			addToken(QUESTION_QUESTION)
+		} else if s.match('.') {
+			addToken(QUESTION_DOT)
+		} else {
+			addToken(QUESTION)
+		}
 	case '*':
-		addToken(STAR)
+		if s.match('*') {
+			s.operator(STAR_STAR, STAR_STAR_EQUAL)
+		} else {
+			s.operator(STAR, STAR_EQUAL)
+		}
+	case '%':
+		s.operator(PERCENT, PERCENT_EQUAL)
+	case '~':
+		if s.match('/') {
+			s.operator(TILDE_SLASH, TILDE_SLASH_EQUAL)
+		} else {
+			addToken(TILDE)
+		}
+	case '&':
+		s.operator(AMPERSAND, AMPERSAND_EQUAL)
+	case '|':
+		s.operator(PIPE, PIPE_EQUAL)
+	case '^':
+		s.operator(CARET, CARET_EQUAL)
 	case '!':
 		if s.match('=') {
 			addToken(BANG_EQUAL)
@@ -178,28 +528,32 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			addToken(EQUAL_EQUAL)
+		} else if s.match('>') {
+			addToken(ARROW)
 		} else {
 			addToken(EQUAL)
 		}
 	case '<':
-		if s.match('=') {
-			addToken(LESS_EQUAL)
+		if s.match('<') {
+			s.operator(LESS_LESS, LESS_LESS_EQUAL)
 		} else {
-			addToken(LESS)
+			s.operator(LESS, LESS_EQUAL)
 		}
 	case '>':
-		if s.match('=') {
-			addToken(GREATER_EQUAL)
+		if s.match('>') {
+			s.operator(GREATER_GREATER, GREATER_GREATER_EQUAL)
 		} else {
-			addToken(GREATER)
+			s.operator(GREATER, GREATER_EQUAL)
 		}
 	case '/':
 		if s.match('/') {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+		} else if s.match('*') {
+			s.blockComment()
 		} else {
-			addToken(SLASH)
+			s.operator(SLASH, SLASH_EQUAL)
 		}
 	case '"':
 		s.string()
@@ -207,6 +561,7 @@ func (s *Scanner) scanToken() {
 		return
 	case '\n':
 		s.line++
+		s.lineStart = s.current
 		return
 	default:
 		if s.isDigit(c) {
@@ -215,7 +570,10 @@ func (s *Scanner) scanToken() {
 		} else if s.isAlpha(c) {
 			s.identifier()
 			return
+		} else if c == utf8.RuneError {
+			// invalid UTF-8 is reported by advance
+			return
 		}
-		reportError(s.line, "Unexpected character.")
+		s.error("Unexpected character.")
 	}
 }
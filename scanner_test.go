@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// scanSource returns the tokens of source, without the final EOF, and the
+// scanner's errors.
+func scanSource(source string) ([]Token, []string) {
+	scanner := NewScanner(source)
+	tokens := scanner.scanTokens()
+	var errors []string
+	for _, err := range scanner.errors {
+		errors = append(errors, err.Error())
+	}
+	return tokens[:len(tokens)-1], errors
+}
+
+// scanTest is source together with the tokens it scans to, each shown as
+// TYPE or TYPE:literal, or the scanner errors it gives.
+type scanTest struct {
+	name   string
+	source string
+	tokens string
+	errors []string
+}
+
+func runScanTests(t *testing.T, tests []scanTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, errors := scanSource(test.source)
+			if strings.Join(errors, "\n") != strings.Join(test.errors, "\n") {
+				t.Fatalf("expected errors\n%s\ngot\n%s", strings.Join(test.errors, "\n"), strings.Join(errors, "\n"))
+			}
+			if test.errors != nil {
+				return
+			}
+			var shown []string
+			for _, token := range tokens {
+				if token.Literal != nil {
+					shown = append(shown, fmt.Sprintf("%s:%#v", token.TokenType, token.Literal))
+				} else {
+					shown = append(shown, string(token.TokenType))
+				}
+			}
+			if strings.Join(shown, " ") != test.tokens {
+				t.Fatalf("expected tokens\n%s\ngot\n%s", test.tokens, strings.Join(shown, " "))
+			}
+		})
+	}
+}
+
+func TestStrings(t *testing.T) {
+	runScanTests(t, []scanTest{
+		{name: "plain", source: `"hi there"`, tokens: `STRING:"hi there"`},
+		{name: "escapes", source: `"a\nb\tc\r\0\"\'\\\$"`, tokens: `STRING:"a\nb\tc\r\x00\"'\\$"`},
+		{name: "unicode escapes", source: `"é\u{1F600}\u{41}"`, tokens: `STRING:"é😀A"`},
+		{name: "interpolation", source: `"a ${b} c"`, tokens: `INTERPOLATION:"a " IDENTIFIER STRING:" c"`},
+		{name: "nested braces", source: `"${f({})}"`,
+			tokens: `INTERPOLATION:"" IDENTIFIER LEFT_PAREN LEFT_BRACE RIGHT_BRACE RIGHT_PAREN STRING:""`},
+		{name: "nested strings", source: `"x${"y${z}"}"`,
+			tokens: `INTERPOLATION:"x" INTERPOLATION:"y" IDENTIFIER STRING:"" STRING:""`},
+		{name: "escaped dollar", source: `"\${a}"`, tokens: `STRING:"${a}"`},
+		{name: "lone dollar", source: `"$5"`, tokens: `STRING:"$5"`},
+
+		{name: "invalid escape", source: `"a\qb"`, errors: []string{`[line 1:3] Error: Invalid escape sequence '\q'.`}},
+		{name: "short unicode escape", source: `"\u12"`, errors: []string{"[line 1:2] Error: Expect 4 hex digits in Unicode escape."}},
+		{name: "unclosed unicode escape", source: `"\u{41"`, errors: []string{"[line 1:2] Error: Expect '}' after Unicode escape."}},
+		{name: "invalid code point", source: `"\u{D800}"`, errors: []string{`[line 1:2] Error: Invalid Unicode escape '\u{D800}'.`}},
+		{name: "invalid 4-digit code point", source: `"\uD800"`, errors: []string{`[line 1:2] Error: Invalid Unicode escape '\uD800'.`}},
+		{name: "too large code point", source: `"\u{110000}"`, errors: []string{`[line 1:2] Error: Invalid Unicode escape '\u{110000}'.`}},
+		{name: "unterminated", source: `"abc`, errors: []string{"[line 1:1] Error: Unterminated string."}},
+		{name: "unterminated interpolation", source: `"a ${b`, errors: []string{"[line 1:7] Error: Unterminated string interpolation."}},
+	})
+}
+
+func TestInterpolation(t *testing.T) {
+	runScriptTests(t, []scriptTest{
+		{name: "values", source: `var name = "Ann"; var age = 41; print "Hello ${name}, you are ${age + 1}";`,
+			output: "Hello Ann, you are 42\n"},
+		{name: "stringified like print", source: `print "${nil} ${true} ${1.5} ${[1, "a"]}";`, output: "<nil> true 1.5 [1, a]\n"},
+		{name: "nested", source: `var a = "in"; print "out ${"mid ${a}"}";`, output: "out mid in\n"},
+		{name: "escapes", source: `print "tab\there!";`, output: "tab\there!\n"},
+		{name: "error inside", source: `print "${-"a"}";`, err: "Operand must be a number, but got string."},
+	})
+}
+
+func TestBlockComments(t *testing.T) {
+	runScanTests(t, []scanTest{
+		{name: "inline", source: "1 /* one */ + 2", tokens: "NUMBER:1 PLUS NUMBER:2"},
+		{name: "nested", source: "/* a /* b */ still comment */ x", tokens: "IDENTIFIER"},
+		{name: "stars and slashes", source: "/** / * **/ x", tokens: "IDENTIFIER"},
+		{name: "line comment inside", source: "/* // */ x", tokens: "IDENTIFIER"},
+		{name: "division after", source: "a /* c */ / b", tokens: "IDENTIFIER SLASH IDENTIFIER"},
+
+		{name: "unterminated", source: "x\n  /* open\n\n", errors: []string{"[line 2:3] Error: Unterminated block comment."}},
+		{name: "unterminated nested", source: "/* /* */", errors: []string{"[line 1:1] Error: Unterminated block comment."}},
+	})
+	tokens, _ := scanSource("/* one\ntwo\n/* three\n*/ */\nx")
+	if tokens[0].Line != 5 || tokens[0].Column != 1 {
+		t.Errorf("expected x at 5:1 after the comment, got %d:%d", tokens[0].Line, tokens[0].Column)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	runScanTests(t, []scanTest{
+		{name: "identifiers", source: "var café = naïve + π + 名前;", tokens: "VAR IDENTIFIER EQUAL IDENTIFIER PLUS IDENTIFIER PLUS IDENTIFIER SEMICOLON"},
+		{name: "combining marks", source: "ét́", tokens: "IDENTIFIER"},
+		{name: "strings", source: `"日本 😀"`, tokens: `STRING:"日本 😀"`},
+
+		{name: "symbol", source: "var a = 1 → 2;", errors: []string{"[line 1:11] Error: Unexpected character."}},
+		{name: "invalid byte", source: "var é = \"a\xffb\";", errors: []string{"[line 1:11] Error: Invalid UTF-8 byte 0xff."}},
+		{name: "invalid byte on a later line", source: "x\n  \xc3", errors: []string{"[line 2:3] Error: Invalid UTF-8 byte 0xc3."}},
+	})
+	tokens, _ := scanSource("\"😀\" + ünï;\n  名前")
+	columns := make([]string, len(tokens))
+	for index, token := range tokens {
+		columns[index] = fmt.Sprintf("%s@%d:%d", token.Lexeme, token.Line, token.Column)
+	}
+	if expected := `"😀"@1:1 +@1:5 ünï@1:7 ;@1:10 名前@2:3`; strings.Join(columns, " ") != expected {
+		t.Errorf("expected columns in runes\n%s\ngot\n%s", expected, strings.Join(columns, " "))
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	runScanTests(t, []scanTest{
+		{name: "integer", source: "42", tokens: "NUMBER:42"},
+		{name: "float", source: "3.25", tokens: "NUMBER:3.25"},
+		{name: "method call on an integer", source: "1.a", tokens: "NUMBER:1 DOT IDENTIFIER"},
+		{name: "hexadecimal", source: "0xFF 0Xff", tokens: "NUMBER:255 NUMBER:255"},
+		{name: "binary", source: "0b1010", tokens: "NUMBER:10"},
+		{name: "octal", source: "0o17", tokens: "NUMBER:15"},
+		{name: "separators", source: "1_000_000 0xFF_FF 1_0.0_1", tokens: "NUMBER:1000000 NUMBER:65535 NUMBER:10.01"},
+		{name: "exponent", source: "1.5e-3 2E3 1e+2", tokens: "NUMBER:0.0015 NUMBER:2000 NUMBER:100"},
+		{name: "largest integer", source: "9223372036854775807", tokens: "NUMBER:9223372036854775807"},
+
+		{name: "no hex digits", source: "0x", errors: []string{"[line 1:1] Error: Expect digits after '0x'."}},
+		{name: "bad hex digit", source: "0xFG", errors: []string{"[line 1:4] Error: Unexpected 'G' in number literal."}},
+		{name: "bad binary digit", source: "0b102", errors: []string{"[line 1:5] Error: Unexpected '2' in number literal."}},
+		{name: "double separator", source: "1__0", errors: []string{"[line 1:2] Error: Misplaced '_' in number literal."}},
+		{name: "trailing separator", source: "x = 10_;", errors: []string{"[line 1:7] Error: Misplaced '_' in number literal."}},
+		{name: "separator after prefix", source: "0x_1", errors: []string{"[line 1:3] Error: Misplaced '_' in number literal."}},
+		{name: "empty exponent", source: "1e", errors: []string{"[line 1:3] Error: Expect digits in exponent."}},
+		{name: "signed empty exponent", source: "1e-", errors: []string{"[line 1:4] Error: Expect digits in exponent."}},
+		{name: "integer too large", source: "9223372036854775808", errors: []string{"[line 1:1] Error: Integer literal is too large."}},
+		{name: "hex too large", source: "0x1_0000_0000_0000_0000", errors: []string{"[line 1:1] Error: Integer literal is too large."}},
+		{name: "float out of range", source: "1e400", errors: []string{"[line 1:1] Error: Number literal is out of range."}},
+		{name: "reported once", source: "12ab + 3", errors: []string{"[line 1:3] Error: Unexpected 'a' in number literal."}},
+	})
+}
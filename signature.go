@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signature describes the arguments a callable accepts.
+type Signature struct {
+	Name string
+	// parameter names, which named arguments refer to; natives may leave
+	// them out, and then only take positional arguments
+	Params []string
+	// the number of arguments without a default
+	MinArity int
+	// the most arguments accepted, or -1 when the last parameter collects
+	// the rest
+	MaxArity int
+}
+
+// omitted stands in for a parameter left out of a call, which takes its
+// default value.
+type omitted struct{}
+
+func fixedSignature(name string, arity int) Signature {
+	return Signature{Name: name, MinArity: arity, MaxArity: arity}
+}
+
+func functionSignature(stmt StmtFunction) Signature {
+	signature := Signature{Name: stmt.Name.Lexeme, MaxArity: len(stmt.Params)}
+	for index, param := range stmt.Params {
+		signature.Params = append(signature.Params, param.Lexeme)
+		if stmt.Defaults[index] == nil && !(stmt.Rest && index == len(stmt.Params)-1) {
+			signature.MinArity = index + 1
+		}
+	}
+	if stmt.Rest {
+		signature.MaxArity = -1
+	}
+	return signature
+}
+
+func (s Signature) variadic() bool {
+	return s.MaxArity < 0
+}
+
+func (s Signature) accepts(count int) bool {
+	return count >= s.MinArity && (s.variadic() || count <= s.MaxArity)
+}
+
+// String shows the signature the way it was declared, with optional
+// parameters in brackets: f(a, [b], ...rest).
+func (s Signature) String() string {
+	var params []string
+	for index, param := range s.Params {
+		switch {
+		case s.variadic() && index == len(s.Params)-1:
+			params = append(params, "..."+param)
+		case index >= s.MinArity:
+			params = append(params, "["+param+"]")
+		default:
+			params = append(params, param)
+		}
+	}
+	if s.Params == nil {
+		// natives without parameter names
+		for index := 0; index < s.MinArity; index++ {
+			params = append(params, fmt.Sprintf("arg%d", index+1))
+		}
+		if s.variadic() {
+			params = append(params, "...")
+		}
+	}
+	return s.Name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// arity describes how many arguments the signature takes.
+func (s Signature) arity() string {
+	switch {
+	case s.variadic():
+		return fmt.Sprintf("at least %d", s.MinArity)
+	case s.MinArity == s.MaxArity:
+		return fmt.Sprint(s.MinArity)
+	}
+	return fmt.Sprintf("%d to %d", s.MinArity, s.MaxArity)
+}
+
+// bind puts the arguments of a call in parameter order. names holds the
+// name of each named argument, which follow the positional ones, and is
+// empty when there are none. Parameters left out are omitted{}.
+func (s Signature) bind(paren Token, names []Token, arguments []interface{}) ([]interface{}, error) {
+	fail := func(format string, args ...interface{}) ([]interface{}, error) {
+		return nil, &RuntimeError{token: paren, message: fmt.Sprintf(format, args...)}
+	}
+	if !s.variadic() && len(arguments) > s.MaxArity || len(names) == 0 && !s.accepts(len(arguments)) {
+		return fail("%v takes %s arguments but got %d.", s, s.arity(), len(arguments))
+	}
+	if len(names) == 0 {
+		return arguments, nil
+	}
+	if len(s.Params) == 0 {
+		return fail("%v doesn't take named arguments.", s)
+	}
+	positional := len(arguments) - len(names)
+	// the parameters arguments can be named for
+	named := len(s.Params)
+	if s.variadic() {
+		named--
+	}
+	bound := make([]interface{}, max(positional, named))
+	copy(bound, arguments[:positional])
+	for index := positional; index < named; index++ {
+		bound[index] = omitted{}
+	}
+	for index, name := range names {
+		param := -1
+		for candidate := 0; candidate < named; candidate++ {
+			if s.Params[candidate] == name.Lexeme {
+				param = candidate
+			}
+		}
+		if param < 0 {
+			return fail("%v has no parameter named '%s'.", s, name.Lexeme)
+		}
+		if _, ok := bound[param].(omitted); !ok {
+			return fail("Argument '%s' of %v is given twice.", name.Lexeme, s)
+		}
+		bound[param] = arguments[positional+index]
+	}
+	for index := 0; index < s.MinArity; index++ {
+		if _, ok := bound[index].(omitted); ok {
+			return fail("Missing argument '%s' of %v.", s.Params[index], s)
+		}
+	}
+	return bound, nil
+}
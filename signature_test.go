@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParameters(t *testing.T) {
+	const f = "fun f(a, b = a * 2, ...rest) { print \"${a} ${b} ${rest}\"; }\n"
+	runScriptTests(t, []scriptTest{
+		{name: "defaults", source: f + "f(1); f(1, 5);", output: "1 2 []\n1 5 []\n"},
+		{name: "rest", source: f + "f(1, 5, 6, 7);", output: "1 5 [6, 7]\n"},
+		{name: "named", source: f + "f(b: 3, a: 1); f(1, b: 4);", output: "1 3 []\n1 4 []\n"},
+		{name: "named skips a default", source: "fun g(a = 1, b = 2, c = 3) { print a + b + c; } g(c: 10);", output: "13\n"},
+		{name: "defaults are evaluated per call", source: "fun g(l = []) { l.push(1); return l.length; } print g(); print g();", output: "1\n1\n"},
+		{name: "only rest", source: "fun g(...all) { return all.length; } print g(); print g(1, 2, 3);", output: "0\n3\n"},
+		{name: "initializer", source: "class C { init(x, y = 0) { this.s = x + y; } } print C(1).s; print C(1, y: 2).s;", output: "1\n3\n"},
+		{name: "method", source: "class C { m(a, b = \"b\") { return a + b; } } print C().m(b: \"c\", a: \"a\");", output: "ac\n"},
+
+		{name: "too few", source: "fun g(a, b) {} g(1);", err: "Error at ')': g(a, b) takes 2 arguments but got 1."},
+		{name: "too many", source: "fun g(a, b) {} g(1, 2, 3);", err: "Error at ')': g(a, b) takes 2 arguments but got 3."},
+		{name: "optional described", source: "fun g(a, b = 1) {} g();", err: "Error at ')': g(a, [b]) takes 1 to 2 arguments but got 0."},
+		{name: "rest described", source: "fun g(a, ...r) {} g();", err: "Error at ')': g(a, ...r) takes at least 1 arguments but got 0."},
+		{name: "unknown name", source: "fun g(a) {} g(z: 1);", err: "Error at ')': g(a) has no parameter named 'z'."},
+		{name: "rest can't be named", source: "fun g(...r) {} g(r: 1);", err: "Error at ')': g(...r) has no parameter named 'r'."},
+		{name: "given twice", source: "fun g(a, b = 1) {} g(1, a: 2);", err: "Error at ')': Argument 'a' of g(a, [b]) is given twice."},
+		{name: "missing", source: "fun g(a, b) {} g(b: 2);", err: "Error at ')': Missing argument 'a' of g(a, b)."},
+		{name: "native", source: "clock(1);", err: "Error at ')': clock() takes 0 arguments but got 1."},
+		{name: "named to a native", source: "var l = [1]; l.get(index: 0);", err: "Error at ')': get(arg1) doesn't take named arguments."},
+	})
+}
+
+func TestParameterSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"fun f(a = 1, b) {}", "Error at 'b': Parameters after one with a default need defaults too"},
+		{"fun f(...r, a) {}", "Error at ',': Rest parameter must be the last"},
+		{"fun f(a) {} f(a: 1, 2);", "Error at '2': Expect named argument after named arguments."},
+	}
+	for _, test := range tests {
+		errors := parseErrors(test.source)
+		if len(errors) == 0 || !strings.Contains(errors[0], test.err) {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, errors)
+		}
+	}
+}
+
+func TestSignatureString(t *testing.T) {
+	tests := []struct {
+		signature Signature
+		text      string
+		arity     string
+	}{
+		{Signature{Name: "f", Params: []string{"a", "b"}, MinArity: 2, MaxArity: 2}, "f(a, b)", "2"},
+		{Signature{Name: "f", Params: []string{"a", "b", "c"}, MinArity: 1, MaxArity: 3}, "f(a, [b], [c])", "1 to 3"},
+		{Signature{Name: "f", Params: []string{"a", "rest"}, MinArity: 1, MaxArity: -1}, "f(a, ...rest)", "at least 1"},
+		{fixedSignature("clock", 0), "clock()", "0"},
+		{Signature{Name: "join", MinArity: 1, MaxArity: -1}, "join(arg1, ...)", "at least 1"},
+	}
+	for _, test := range tests {
+		if text := test.signature.String(); text != test.text {
+			t.Errorf("expected %q, got %q", test.text, text)
+		}
+		if arity := test.signature.arity(); arity != test.arity {
+			t.Errorf("%s: expected arity %q, got %q", test.text, test.arity, arity)
+		}
+	}
+}
@@ -14,11 +14,26 @@ type StmtVisitor interface {
   visitStmtFunction (expr StmtFunction) error
   visitStmtReturn (expr StmtReturn) error
   visitStmtClass (expr StmtClass) error
+  visitStmtMatch (expr StmtMatch) error
+  visitStmtDestructure (expr StmtDestructure) error
+  visitStmtYield (expr StmtYield) error
+  visitStmtForIn (expr StmtForIn) error
+  visitStmtSelect (expr StmtSelect) error
 }
 
 type StmtVarDeclaration struct {
 	Name        Token
 	Initializer *Expr
+	Type        *TypeAnnotation
+	// set for const declarations, which can't be assigned to
+	Const bool
+}
+
+// TypeAnnotation is the type written after a name, as in var x: Number.
+// Optional types, written Number?, also admit nil.
+type TypeAnnotation struct {
+	Name     Token
+	Optional bool
 }
 
 type StmtReturn struct {
@@ -31,16 +46,19 @@ type StmtExpression struct {
 }
 
 type StmtPrint struct {
+	Keyword    Token
 	Expression Expr
 }
 
 type StmtIf struct {
+  Keyword Token
   Condition Expr
   ThenBranch Stmt
   ElseBranch Stmt
 }
 
 type StmtWhile struct {
+  Keyword Token
   Condition Expr 
   Body Stmt
 }
@@ -52,13 +70,83 @@ type StmtBlock struct {
 type StmtFunction struct {
   Name Token
   Params []Token
+  // one per parameter, nil where there is no annotation
+  ParamTypes []*TypeAnnotation
+  // one per parameter, nil where there is no default value
+  Defaults []*Expr
+  // set when the last parameter collects the remaining arguments
+  Rest bool
+  ReturnType *TypeAnnotation
   Body []*Stmt
+  // set when the body yields, which makes calls return a generator
+  Generator bool
 }
 
 type StmtClass struct {
   Name Token
   Methods []Stmt
   Superclass *ExprVariable
+  Fields []FieldDeclaration
+}
+
+// FieldDeclaration declares the type of a field, as in x: Number; inside a
+// class body. It has no effect at runtime.
+type FieldDeclaration struct {
+  Name Token
+  Type *TypeAnnotation
+}
+
+// StmtMatch runs the first of its Cases with a pattern matching Subject.
+type StmtMatch struct {
+  Keyword Token
+  Subject Expr
+  Cases []MatchCase
+}
+
+// StmtDestructure declares the names of Target, taking their values
+// from the parts of Initializer.
+type StmtDestructure struct {
+  Keyword Token
+  Target Destructuring
+  Initializer Expr
+  // set for const declarations, which can't be assigned to
+  Const bool
+}
+
+// StmtYield hands Value to the caller of a generator's next().
+type StmtYield struct {
+  Keyword Token
+  Value Expr
+}
+
+// StmtForIn runs Body once for each element of a list or each value a
+// generator yields, with Name bound to it.
+type StmtForIn struct {
+  Keyword Token
+  Name Token
+  Iterable Expr
+  Body Stmt
+}
+
+// StmtSelect runs the first of its Cases whose channel is ready, waiting
+// for one unless there is a default case.
+type StmtSelect struct {
+  Keyword Token
+  Cases []SelectCase
+}
+
+// SelectCase is case ch.send(value) =>, case ch.recv() => or
+// case var name = ch.recv() =>, which binds the value received. The
+// default case has no Channel.
+type SelectCase struct {
+  Keyword Token
+  Channel Expr
+  // the send or recv method
+  Operation Token
+  // the value sent
+  Value Expr
+  Name *Token
+  Body Stmt
 }
 
 func (stmt StmtVarDeclaration) accept(visitor StmtVisitor) error {
@@ -96,3 +184,61 @@ func (stmt StmtReturn) accept(visitor StmtVisitor) error {
 func (stmt StmtClass) accept(visitor StmtVisitor) error {
   return visitor.visitStmtClass(stmt)
 }
+
+func (stmt StmtMatch) accept(visitor StmtVisitor) error {
+  return visitor.visitStmtMatch(stmt)
+}
+
+func (stmt StmtDestructure) accept(visitor StmtVisitor) error {
+  return visitor.visitStmtDestructure(stmt)
+}
+
+func (stmt StmtYield) accept(visitor StmtVisitor) error {
+  return visitor.visitStmtYield(stmt)
+}
+
+func (stmt StmtForIn) accept(visitor StmtVisitor) error {
+  return visitor.visitStmtForIn(stmt)
+}
+
+func (stmt StmtSelect) accept(visitor StmtVisitor) error {
+  return visitor.visitStmtSelect(stmt)
+}
+
+// stmtLine returns the source line a statement starts on, or 0 when it
+// cannot be told.
+func stmtLine(stmt Stmt) int {
+	switch s := stmt.(type) {
+	case StmtVarDeclaration:
+		return s.Name.Line
+	case StmtExpression:
+		return exprLine(*s.Expression)
+	case StmtPrint:
+		return s.Keyword.Line
+	case StmtIf:
+		return s.Keyword.Line
+	case StmtWhile:
+		return s.Keyword.Line
+	case StmtBlock:
+		if len(s.Statements) > 0 {
+			return stmtLine(*s.Statements[0])
+		}
+	case StmtFunction:
+		return s.Name.Line
+	case StmtReturn:
+		return s.Keyword.Line
+	case StmtClass:
+		return s.Name.Line
+	case StmtMatch:
+		return s.Keyword.Line
+	case StmtDestructure:
+		return s.Keyword.Line
+	case StmtYield:
+		return s.Keyword.Line
+	case StmtForIn:
+		return s.Keyword.Line
+	case StmtSelect:
+		return s.Keyword.Line
+	}
+	return 0
+}
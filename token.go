@@ -7,15 +7,17 @@ type Token struct {
   Lexeme string
   Literal interface{}
   Line int
+  Column int
 }
 
 // NewToken is a constructor function that initializes a Token with default values
-func NewToken(tokenType TokenType, lexeme string, literal interface{}, line int) Token {
+func NewToken(tokenType TokenType, lexeme string, literal interface{}, line int, column int) Token {
 	return Token{
 		TokenType: tokenType,
 		Lexeme:    lexeme,
 		Literal:   literal,
 		Line:      line,
+		Column:    column,
 	}
 }
 
@@ -8,13 +8,17 @@ const (
   RIGHT_PAREN TokenType = "RIGHT_PAREN"
   LEFT_BRACE TokenType = "LEFT_BRACE"
   RIGHT_BRACE TokenType = "RIGHT_BRACE" 
+  LEFT_BRACKET TokenType = "LEFT_BRACKET"
+  RIGHT_BRACKET TokenType = "RIGHT_BRACKET"
   COMMA TokenType = "COMMA"
   DOT TokenType = "DOT"
+  DOT_DOT_DOT TokenType = "DOT_DOT_DOT"
   MINUS TokenType = "MINUS"
   PLUS TokenType = "PLUS"
   SEMICOLON TokenType = "SEMICOLON"
   SLASH TokenType = "SLASH"
   STAR TokenType = "STAR"
+  PERCENT TokenType = "PERCENT"
   // One or two character tokens.
   BANG TokenType = "BANG"
   BANG_EQUAL TokenType = "BANG_EQUAL"
@@ -24,9 +28,39 @@ const (
   GREATER_EQUAL TokenType = "GREATER_EQUAL"
   LESS TokenType = "LESS"
   LESS_EQUAL TokenType = "LESS_EQUAL"
+  TILDE_SLASH TokenType = "TILDE_SLASH"
+  STAR_STAR TokenType = "STAR_STAR"
+  QUESTION TokenType = "QUESTION"
+  QUESTION_QUESTION TokenType = "QUESTION_QUESTION"
+  QUESTION_DOT TokenType = "QUESTION_DOT"
+  COLON TokenType = "COLON"
+  ARROW TokenType = "ARROW"
+  AMPERSAND TokenType = "AMPERSAND"
+  PIPE TokenType = "PIPE"
+  CARET TokenType = "CARET"
+  TILDE TokenType = "TILDE"
+  LESS_LESS TokenType = "LESS_LESS"
+  GREATER_GREATER TokenType = "GREATER_GREATER"
+  PLUS_PLUS TokenType = "PLUS_PLUS"
+  MINUS_MINUS TokenType = "MINUS_MINUS"
+  // Compound assignment.
+  PLUS_EQUAL TokenType = "PLUS_EQUAL"
+  MINUS_EQUAL TokenType = "MINUS_EQUAL"
+  STAR_EQUAL TokenType = "STAR_EQUAL"
+  SLASH_EQUAL TokenType = "SLASH_EQUAL"
+  PERCENT_EQUAL TokenType = "PERCENT_EQUAL"
+  TILDE_SLASH_EQUAL TokenType = "TILDE_SLASH_EQUAL"
+  STAR_STAR_EQUAL TokenType = "STAR_STAR_EQUAL"
+  AMPERSAND_EQUAL TokenType = "AMPERSAND_EQUAL"
+  PIPE_EQUAL TokenType = "PIPE_EQUAL"
+  CARET_EQUAL TokenType = "CARET_EQUAL"
+  LESS_LESS_EQUAL TokenType = "LESS_LESS_EQUAL"
+  GREATER_GREATER_EQUAL TokenType = "GREATER_GREATER_EQUAL"
   // Literals.
   IDENTIFIER TokenType = "IDENTIFIER"
   STRING TokenType = "STRING"
+  // the text of a string up to an interpolated ${expression}
+  INTERPOLATION TokenType = "INTERPOLATION"
   NUMBER TokenType = "NUMBER"
   // Keywords.
   AND TokenType = "AND"
@@ -44,7 +78,15 @@ const (
   THIS TokenType = "THIS"
   TRUE TokenType = "TRUE"
   VAR TokenType = "VAR"
+  CONST TokenType = "CONST"
   WHILE TokenType = "WHILE"
+  MATCH TokenType = "MATCH"
+  CASE TokenType = "CASE"
+  DEFAULT TokenType = "DEFAULT"
+  YIELD TokenType = "YIELD"
+  IN TokenType = "IN"
+  SPAWN TokenType = "SPAWN"
+  SELECT TokenType = "SELECT"
   EOF TokenType = "EOF"
 )
 